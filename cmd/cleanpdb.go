@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"kirill/pkg/structure"
+
+	"github.com/spf13/cobra"
+)
+
+// cleanStructureFile cleans input and writes the result to output in the
+// given format, or in the input's format if it is empty.
+func cleanStructureFile(input, output string, opts structure.CleanOptions, format structure.Format) (structure.CleanSummary, error) {
+	s, inputFormat, err := structure.ReadFile(input)
+	if err != nil {
+		return structure.CleanSummary{}, err
+	}
+	if format == "" {
+		format = inputFormat
+	}
+
+	summary := structure.Clean(s, opts)
+	if len(s.Models) == 0 || len(s.Models[0].Chains) == 0 {
+		return summary, fmt.Errorf("%s: no atoms left after cleaning", input)
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return summary, err
+	}
+	if err := structure.Write(file, s, format); err != nil {
+		file.Close()
+		os.Remove(output)
+		return summary, fmt.Errorf("%s: %w", input, err)
+	}
+	return summary, file.Close()
+}
+
+// sameFile reports whether two paths name the same file, so that inputs are
+// not overwritten while they are read.
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+var cleanpdbCmd = &cobra.Command{
+	Use:   "cleanpdb [structure files]",
+	Short: "Prepare structure files for docking or simulation",
+	Long: `cleanpdb removes what is usually removed by hand before docking or molecular
+dynamics from structure files, such as those downloaded by fetchpdb. Input can
+be in PDB or mmCIF format, gzipped or not. Each step is selected with a flag:
+
+  --remove-waters   remove water molecules (on by default)
+  --altloc          keep one alternate location: highest (by occupancy, the
+                    default), an indicator such as A, or all
+  --remove-hetero   remove HETATM residues by name, or all of them
+  --chains          keep only these chains
+  --renumber        number residues of each chain from 1
+
+Cleaned files are written to the output directory as <name>_clean.<ext>, in
+the input format unless --to is given. The log records what was removed from
+each file.
+
+Example usage:
+
+1. Keep chain A of an entry without waters, sulfate or glycerol:
+   kirill cleanpdb 1ABC.pdb --chains A --remove-hetero SO4,GOL
+
+2. Clean every downloaded entry, dropping all ligands and renumbering:
+   kirill cleanpdb structures/*.cif --remove-hetero all --renumber --to pdb -o cleaned`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputPath, _ := cmd.Flags().GetString("output")
+		removeWaters, _ := cmd.Flags().GetBool("remove-waters")
+		altLoc, _ := cmd.Flags().GetString("altloc")
+		removeHetero, _ := cmd.Flags().GetStringSlice("remove-hetero")
+		chains, _ := cmd.Flags().GetStringSlice("chains")
+		renumber, _ := cmd.Flags().GetBool("renumber")
+		to, _ := cmd.Flags().GetString("to")
+		suffix, _ := cmd.Flags().GetString("suffix")
+
+		var logFile *os.File
+		var err error
+
+		logPath := path.Join(outputPath, "cleanpdb")
+		logger, logFile, err = getLogger(logPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		logger.Println(getCommandLine())
+
+		if altLoc == "all" {
+			altLoc = ""
+		}
+		for i, name := range removeHetero {
+			if strings.EqualFold(name, "all") {
+				removeHetero[i] = "all"
+			} else {
+				removeHetero[i] = strings.ToUpper(name)
+			}
+		}
+		opts := structure.CleanOptions{
+			RemoveWaters: removeWaters,
+			AltLoc:       altLoc,
+			RemoveHetero: removeHetero,
+			Chains:       chains,
+			Renumber:     renumber,
+		}
+
+		var format structure.Format
+		if to != "" {
+			if format, err = structure.ParseFormat(to); err != nil {
+				logger.Fatalln(err)
+			}
+		}
+
+		failed := 0
+		for _, input := range args {
+			ext := filepath.Ext(strings.TrimSuffix(input, ".gz"))
+			if format != "" {
+				ext = format.Extension()
+			}
+			output := path.Join(outputPath, structureBaseName(input)+suffix+ext)
+			if sameFile(input, output) {
+				logger.Printf("%s: refusing to overwrite the input, set --suffix or --output", input)
+				failed++
+				continue
+			}
+
+			summary, err := cleanStructureFile(input, output, opts, format)
+			if err != nil {
+				logger.Println(err)
+				failed++
+				continue
+			}
+			logger.Printf("Cleaned %s into %s: %v", input, output, summary)
+		}
+		logger.Printf("Cleaned %d of %d files", len(args)-failed, len(args))
+
+		if failed > 0 {
+			logFile.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanpdbCmd)
+
+	cleanpdbCmd.Flags().StringP("output", "o", ".", "Output directory")
+	cleanpdbCmd.Flags().BoolP("remove-waters", "", true, "Remove water molecules")
+	cleanpdbCmd.Flags().StringP("altloc", "", structure.AltLocHighest, "Alternate location to keep: highest, an indicator such as A, or all")
+	cleanpdbCmd.Flags().StringSliceP("remove-hetero", "", nil, "HETATM residue names to remove, or all")
+	cleanpdbCmd.Flags().StringSliceP("chains", "", nil, "Chains to keep; all by default")
+	cleanpdbCmd.Flags().BoolP("renumber", "", false, "Number the residues of each chain from 1")
+	cleanpdbCmd.Flags().StringP("to", "t", "", "Output format: pdb or cif; the input format by default")
+	cleanpdbCmd.Flags().StringP("suffix", "", "_clean", "Suffix added to the names of cleaned files")
+}
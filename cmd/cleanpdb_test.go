@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"kirill/pkg/structure"
+)
+
+func Test_cleanStructureFile(t *testing.T) {
+	dir := t.TempDir()
+	input := path.Join(dir, "1ABC.pdb")
+	if err := ioutil.WriteFile(input, []byte(testConvertPDB), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := path.Join(dir, "1ABC_clean.cif")
+	summary, err := cleanStructureFile(input, output, structure.CleanOptions{RemoveWaters: true}, structure.FormatCIF)
+	if err != nil {
+		t.Fatalf("cleanStructureFile() returned error: %v", err)
+	}
+	if summary.Waters != 1 {
+		t.Errorf("Expected 1 water removed, got %+v", summary)
+	}
+
+	content, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "data_1ABC") || strings.Contains(string(content), "HOH") {
+		t.Errorf("Expected mmCIF output without waters, got:\n%s", content)
+	}
+
+	if _, err := cleanStructureFile(input, output, structure.CleanOptions{Chains: []string{"Z"}}, ""); err == nil {
+		t.Errorf("Expected an error when no atoms are left")
+	}
+}
+
+func Test_sameFile(t *testing.T) {
+	if !sameFile("structures/1abc.pdb", "structures/../structures/1abc.pdb") {
+		t.Errorf("Expected equivalent paths to name the same file")
+	}
+	if sameFile("1abc.pdb", "1abc_clean.pdb") {
+		t.Errorf("Expected different files")
+	}
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"kirill/pkg/structure"
+
+	"github.com/spf13/cobra"
+)
+
+// structureBaseName strips the directory and the structure and compression
+// extensions from a file name, e.g. 1abc.cif.gz becomes 1abc.
+func structureBaseName(filename string) string {
+	base := filepath.Base(filename)
+	base = strings.TrimSuffix(base, ".gz")
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// convertStructureFile reads input in either format and writes it to output
+// in the given one. output is replaced only once the structure is written in
+// full, and never if it is the input.
+func convertStructureFile(input, output string, format structure.Format) error {
+	if sameFile(input, output) {
+		return fmt.Errorf("%s: refusing to overwrite the input, set --output", input)
+	}
+	s, _, err := structure.ReadFile(input)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := structure.Write(&buf, s, format); err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+	_, _, err = writeFileAtomic(output, &buf)
+	return err
+}
+
+var convertCmd = &cobra.Command{
+	Use:   "convert [structure files]",
+	Short: "Convert structure files between PDB and mmCIF format",
+	Long: `convert reads structure files in legacy PDB or PDBx/mmCIF format, gzipped or
+not, and writes them in the format given with --to. The input format is
+detected from the content. Output files are named after the input files with
+the extension of the new format, e.g. 1abc.cif.gz becomes 1abc.pdb. Inputs
+that would be overwritten by their output are logged and skipped.
+
+Example usage:
+
+1. Convert an mmCIF file to PDB format:
+   kirill convert 1abc.cif --to pdb
+
+2. Convert downloaded PDB files to mmCIF into another directory:
+   kirill convert structures/*.pdb --to cif -o converted
+
+Atoms and models are converted together with the entry ID, title,
+experimental method and resolution, the full chain sequences (SEQRES records
+or _pdbx_poly_seq_scheme) and modified residues (MODRES records or
+_pdbx_struct_mod_residue); other records are not. Structures that do not fit
+in PDB format, such as those with chain IDs longer than one character, residue
+names longer than three characters or coordinates too large for their
+columns, are logged and skipped, and the exit code is non-zero.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputPath, _ := cmd.Flags().GetString("output")
+		to, _ := cmd.Flags().GetString("to")
+
+		var logFile *os.File
+		var err error
+
+		logPath := path.Join(outputPath, "convert")
+		logger, logFile, err = getLogger(logPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		logger.Println(getCommandLine())
+
+		format, err := structure.ParseFormat(to)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+
+		failed := 0
+		for _, input := range args {
+			output := path.Join(outputPath, structureBaseName(input)+format.Extension())
+			if err := convertStructureFile(input, output, format); err != nil {
+				logger.Println(err)
+				failed++
+				continue
+			}
+			logger.Printf("Converted %s to %s", input, output)
+		}
+		logger.Printf("Converted %d of %d files", len(args)-failed, len(args))
+
+		if failed > 0 {
+			logFile.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().StringP("output", "o", ".", "Output directory")
+	convertCmd.Flags().StringP("to", "t", "", "Output format: pdb or cif")
+	convertCmd.MarkFlagRequired("to")
+}
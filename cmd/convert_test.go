@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"kirill/pkg/structure"
+)
+
+const testConvertPDB = `HEADER    TEST                                    01-JAN-00   1ABC              
+ATOM      1  CA  GLY A   1       1.000   2.000   3.000  1.00 10.00           C  
+HETATM    2  O   HOH A 101       4.000   5.000   6.000  1.00 20.00           O  
+END
+`
+
+func Test_structureBaseName(t *testing.T) {
+	testCases := []struct {
+		filename string
+		expected string
+	}{
+		{"1abc.cif", "1abc"},
+		{"structures/1abc.cif.gz", "1abc"},
+		{"AF-P69905-F1-model_v4.pdb", "AF-P69905-F1-model_v4"},
+		{"pdb1abc.ent.gz", "pdb1abc"},
+	}
+
+	for _, tc := range testCases {
+		if base := structureBaseName(tc.filename); base != tc.expected {
+			t.Errorf("structureBaseName(%q): expected %q, got %q", tc.filename, tc.expected, base)
+		}
+	}
+}
+
+func Test_convertStructureFile(t *testing.T) {
+	dir := t.TempDir()
+	input := path.Join(dir, "1abc.pdb.gz")
+	file, err := os.Create(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	gz.Write([]byte(testConvertPDB))
+	gz.Close()
+	file.Close()
+
+	cif := path.Join(dir, "1abc.cif")
+	if err := convertStructureFile(input, cif, structure.FormatCIF); err != nil {
+		t.Fatalf("convertStructureFile() to cif returned error: %v", err)
+	}
+	content, err := ioutil.ReadFile(cif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "data_1ABC\n") || !strings.Contains(string(content), "HETATM 2 O O . HOH A . ? 4.000 5.000 6.000") {
+		t.Errorf("Unexpected mmCIF output:\n%s", content)
+	}
+
+	pdb := path.Join(dir, "1abc.pdb")
+	if err := convertStructureFile(cif, pdb, structure.FormatPDB); err != nil {
+		t.Fatalf("convertStructureFile() back to pdb returned error: %v", err)
+	}
+	s, format, err := structure.ReadFile(pdb)
+	if err != nil || format != structure.FormatPDB {
+		t.Fatalf("Expected a PDB file, got %s, %v", format, err)
+	}
+	if atoms := s.Models[0].Atoms(); s.ID != "1ABC" || len(atoms) != 2 || atoms[1].Z != 6 {
+		t.Errorf("Unexpected structure after round trip: %+v", s)
+	}
+}
+
+func Test_convertStructureFile_unsupported(t *testing.T) {
+	dir := t.TempDir()
+	input := path.Join(dir, "long.cif")
+	content := `data_long
+loop_
+_atom_site.group_PDB
+_atom_site.auth_asym_id
+_atom_site.auth_seq_id
+_atom_site.auth_comp_id
+_atom_site.auth_atom_id
+_atom_site.Cartn_x
+_atom_site.Cartn_y
+_atom_site.Cartn_z
+ATOM AAA 1 GLY CA 1.0 2.0 3.0
+`
+	if err := ioutil.WriteFile(input, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := path.Join(dir, "long.pdb")
+	if err := ioutil.WriteFile(output, []byte(testConvertPDB), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := convertStructureFile(input, output, structure.FormatPDB); err == nil {
+		t.Errorf("Expected an error for a chain ID that does not fit in PDB format")
+	}
+	if written, err := ioutil.ReadFile(output); err != nil || string(written) != testConvertPDB {
+		t.Errorf("Expected the previous output to be kept, got %q, %v", written, err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 2 {
+		t.Errorf("Expected no partial output to be left behind, got %d files", len(files))
+	}
+}
+
+func Test_convertStructureFile_sameFile(t *testing.T) {
+	dir := t.TempDir()
+	input := path.Join(dir, "1abc.pdb")
+	if err := ioutil.WriteFile(input, []byte(testConvertPDB), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := convertStructureFile(input, path.Join(dir, ".", "1abc.pdb"), structure.FormatPDB); err == nil {
+		t.Errorf("Expected an error when converting a file onto itself")
+	}
+	if content, err := ioutil.ReadFile(input); err != nil || string(content) != testConvertPDB {
+		t.Errorf("Expected the input to be left as is, got %q, %v", content, err)
+	}
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// entryFilter selects entries by their metadata before anything is
+// downloaded. Zero values do not filter.
+type entryFilter struct {
+	maxResolution  float64
+	methods        []string
+	minReleaseDate string
+}
+
+func (f entryFilter) active() bool {
+	return f.maxResolution > 0 || len(f.methods) > 0 || f.minReleaseDate != ""
+}
+
+func (f entryFilter) validate() error {
+	if f.minReleaseDate == "" {
+		return nil
+	}
+	if _, err := time.Parse("2006-01-02", f.minReleaseDate); err != nil {
+		return fmt.Errorf("invalid release date %q, expected YYYY-MM-DD", f.minReleaseDate)
+	}
+	return nil
+}
+
+// experimentalMethod resolves the short names of experimentalMethods.
+func experimentalMethod(name string) string {
+	name = strings.TrimSpace(name)
+	if method, ok := experimentalMethods[strings.ToLower(name)]; ok {
+		return method
+	}
+	return strings.ToUpper(name)
+}
+
+// reject returns why an entry does not pass the filter, or "" if it does.
+func (f entryFilter) reject(m entryMetadata) string {
+	if len(f.methods) > 0 {
+		matched := false
+		for _, wanted := range f.methods {
+			for _, method := range m.methods {
+				matched = matched || experimentalMethod(wanted) == method
+			}
+		}
+		if !matched {
+			return fmt.Sprintf("method %s is not %s", strings.Join(m.methods, ", "), strings.Join(f.methods, " or "))
+		}
+	}
+
+	if f.maxResolution > 0 {
+		if m.resolution == 0 {
+			return "no resolution reported"
+		}
+		if m.resolution > f.maxResolution {
+			return fmt.Sprintf("resolution %g Å is above %g Å", m.resolution, f.maxResolution)
+		}
+	}
+
+	if f.minReleaseDate != "" {
+		if m.releaseDate == "" {
+			return "no release date reported"
+		}
+		if m.releaseDate < f.minReleaseDate {
+			return fmt.Sprintf("released %s, before %s", m.releaseDate, f.minReleaseDate)
+		}
+	}
+	return ""
+}
+
+// filterEntries looks up the metadata of the valid IDs among tokens and
+// returns the reason for skipping each entry that does not pass filter.
+// Entries without metadata are not filtered, so their download can report
+// what is wrong with them.
+func filterEntries(client *PDBClient, url string, tokens []string, filter entryFilter) (map[string]string, error) {
+	var ids []string
+	for _, token := range tokens {
+		if id, err := normalizePDBIdToken(token); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	logger.Printf("Looking up metadata of %d entries to filter them", len(ids))
+	metadata, err := client.entryMetadata(url, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	rejected := make(map[string]string)
+	for _, id := range ids {
+		m, ok := metadata[id]
+		if !ok {
+			logger.Printf("No metadata found for %s, downloading it unfiltered", id)
+			continue
+		}
+		if reason := filter.reject(m); reason != "" {
+			logger.Printf("Skipping %s: %s", id, reason)
+			rejected[id] = reason
+		}
+	}
+	logger.Printf("%d of %d entries pass the filters", len(ids)-len(rejected), len(ids))
+	return rejected, nil
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_entryFilter_reject(t *testing.T) {
+	xray := entryMetadata{id: "4hhb", methods: []string{"X-RAY DIFFRACTION"}, resolution: 1.74, releaseDate: "1984-07-17"}
+	nmr := entryMetadata{id: "2k39", methods: []string{"SOLUTION NMR"}, releaseDate: "2008-06-03"}
+	hybrid := entryMetadata{id: "5b0x", methods: []string{"X-RAY DIFFRACTION", "NEUTRON DIFFRACTION"}, resolution: 2.6, releaseDate: "2016-03-02"}
+	unreleased := entryMetadata{id: "9zzz", methods: []string{"ELECTRON MICROSCOPY"}, resolution: 3.1}
+
+	testCases := []struct {
+		name   string
+		filter entryFilter
+		entry  entryMetadata
+		reject bool
+	}{
+		{"No filter", entryFilter{}, nmr, false},
+		{"Resolution below cutoff", entryFilter{maxResolution: 2.5}, xray, false},
+		{"Resolution above cutoff", entryFilter{maxResolution: 2.5}, hybrid, true},
+		{"No resolution", entryFilter{maxResolution: 2.5}, nmr, true},
+		{"Method by short name", entryFilter{methods: []string{"xray"}}, xray, false},
+		{"Method by exptl name", entryFilter{methods: []string{"solution nmr"}}, nmr, false},
+		{"Any of several methods", entryFilter{methods: []string{"em", "neutron"}}, hybrid, false},
+		{"Other method", entryFilter{methods: []string{"xray"}}, nmr, true},
+		{"Released after date", entryFilter{minReleaseDate: "2000-01-01"}, nmr, false},
+		{"Released before date", entryFilter{minReleaseDate: "2000-01-01"}, xray, true},
+		{"Released on date", entryFilter{minReleaseDate: "2008-06-03"}, nmr, false},
+		{"No release date", entryFilter{minReleaseDate: "2020-01-01"}, unreleased, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason := tc.filter.reject(tc.entry)
+			if (reason != "") != tc.reject {
+				t.Errorf("Expected reject: %v, got reason %q", tc.reject, reason)
+			}
+		})
+	}
+
+	if reason := (entryFilter{minReleaseDate: "2020-01-01"}).reject(unreleased); reason != "no release date reported" {
+		t.Errorf("Expected a missing release date as the reason, got %q", reason)
+	}
+}
+
+func Test_fetchPDB_filtered(t *testing.T) {
+	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": {"entries": [
+			{"rcsb_id": "1ABC", "exptl": [{"method": "X-RAY DIFFRACTION"}], "rcsb_entry_info": {"resolution_combined": [1.9]}},
+			{"rcsb_id": "2DEF", "exptl": [{"method": "X-RAY DIFFRACTION"}], "rcsb_entry_info": {"resolution_combined": [3.2]}},
+			{"rcsb_id": "3GHI", "exptl": [{"method": "SOLUTION NMR"}]}
+		]}}`)
+	}))
+	defer metadataServer.Close()
+
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write([]byte("HEADER    dummy pdb data"))
+	}))
+	defer ts.Close()
+
+	logger = log.New(ioutil.Discard, "", 0)
+	client := &PDBClient{
+		mirrors: testMirrors(ts),
+		client:  &http.Client{},
+	}
+
+	ids := []string{"1abc", "2def", "3ghi", "4jkl"}
+	rejected, err := filterEntries(client, metadataServer.URL, ids, entryFilter{maxResolution: 2.5})
+	if err != nil {
+		t.Fatalf("filterEntries() returned error: %v", err)
+	}
+	client.rejected = rejected
+
+	results := fetchPDB(ids, t.TempDir(), client, 1)
+
+	expected := []fetchStatus{statusOK, statusFiltered, statusFiltered, statusOK}
+	for i, status := range expected {
+		if results[i].status != status {
+			t.Errorf("Result %d: expected %s, got %s (%v)", i, status, results[i].status, results[i].err)
+		}
+	}
+	if !strings.Contains(results[1].err.Error(), "3.2") {
+		t.Errorf("Expected the resolution in the skip reason, got %v", results[1].err)
+	}
+	if len(requested) != 2 {
+		t.Errorf("Expected only unfiltered entries to be downloaded, got %v", requested)
+	}
+	if countFailed(results) != 0 {
+		t.Errorf("Expected filtered entries not to count as failed")
+	}
+}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	rcsbGraphQLURL = "https://data.rcsb.org/graphql"
+
+	// metadataBatchSize is the number of entries requested per query.
+	metadataBatchSize = 200
+)
+
+// entryMetadataQuery asks the RCSB Data API for the fields of every entry
+// that make up an entryMetadata.
+const entryMetadataQuery = `query($ids: [String!]!) {
+  entries(entry_ids: $ids) {
+    rcsb_id
+    struct { title }
+    exptl { method }
+    refine { ls_R_factor_R_free }
+    rcsb_accession_info { deposit_date initial_release_date }
+    rcsb_entry_info {
+      resolution_combined
+      polymer_entity_count
+      deposited_polymer_entity_instance_count
+    }
+    polymer_entities { rcsb_entity_source_organism { scientific_name } }
+    nonpolymer_entities { nonpolymer_comp { chem_comp { id } } }
+  }
+}`
+
+// entryMetadata summarizes a PDB entry. Zero values stand for information
+// the entry does not have, such as the resolution of an NMR structure.
+type entryMetadata struct {
+	id          string
+	title       string
+	methods     []string
+	resolution  float64
+	rFree       float64
+	depositDate string
+	releaseDate string
+	organisms   []string
+	entities    int
+	chains      int
+	ligands     []string
+}
+
+// graphQLEntry is an entry as returned for entryMetadataQuery.
+type graphQLEntry struct {
+	RcsbID string `json:"rcsb_id"`
+	Struct *struct {
+		Title string `json:"title"`
+	} `json:"struct"`
+	Exptl []struct {
+		Method string `json:"method"`
+	} `json:"exptl"`
+	Refine []struct {
+		RFree *float64 `json:"ls_R_factor_R_free"`
+	} `json:"refine"`
+	AccessionInfo *struct {
+		DepositDate        string `json:"deposit_date"`
+		InitialReleaseDate string `json:"initial_release_date"`
+	} `json:"rcsb_accession_info"`
+	EntryInfo *struct {
+		ResolutionCombined []float64 `json:"resolution_combined"`
+		PolymerEntityCount int       `json:"polymer_entity_count"`
+		ChainCount         int       `json:"deposited_polymer_entity_instance_count"`
+	} `json:"rcsb_entry_info"`
+	PolymerEntities []struct {
+		SourceOrganisms []struct {
+			ScientificName string `json:"scientific_name"`
+		} `json:"rcsb_entity_source_organism"`
+	} `json:"polymer_entities"`
+	NonpolymerEntities []struct {
+		Comp *struct {
+			ChemComp *struct {
+				ID string `json:"id"`
+			} `json:"chem_comp"`
+		} `json:"nonpolymer_comp"`
+	} `json:"nonpolymer_entities"`
+}
+
+type graphQLResponse struct {
+	Data struct {
+		Entries []*graphQLEntry `json:"entries"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// metadata flattens a graphQLEntry. Dates are cut to YYYY-MM-DD, organisms
+// and ligands are listed once each in sorted order.
+func (e *graphQLEntry) metadata() entryMetadata {
+	m := entryMetadata{id: strings.ToLower(e.RcsbID)}
+	if id, err := normalizePDBId(e.RcsbID); err == nil {
+		m.id = id
+	}
+
+	if e.Struct != nil {
+		m.title = e.Struct.Title
+	}
+	for _, exptl := range e.Exptl {
+		m.methods = append(m.methods, exptl.Method)
+	}
+	for _, refine := range e.Refine {
+		if refine.RFree != nil {
+			m.rFree = *refine.RFree
+			break
+		}
+	}
+	if e.AccessionInfo != nil {
+		m.depositDate = shortDate(e.AccessionInfo.DepositDate)
+		m.releaseDate = shortDate(e.AccessionInfo.InitialReleaseDate)
+	}
+	if e.EntryInfo != nil {
+		for _, resolution := range e.EntryInfo.ResolutionCombined {
+			if m.resolution == 0 || resolution < m.resolution {
+				m.resolution = resolution
+			}
+		}
+		m.entities = e.EntryInfo.PolymerEntityCount
+		m.chains = e.EntryInfo.ChainCount
+	}
+
+	var organisms, ligands []string
+	for _, entity := range e.PolymerEntities {
+		for _, organism := range entity.SourceOrganisms {
+			organisms = append(organisms, organism.ScientificName)
+		}
+	}
+	for _, entity := range e.NonpolymerEntities {
+		if entity.Comp != nil && entity.Comp.ChemComp != nil {
+			ligands = append(ligands, entity.Comp.ChemComp.ID)
+		}
+	}
+	m.organisms = uniqueSorted(organisms)
+	m.ligands = uniqueSorted(ligands)
+	return m
+}
+
+func shortDate(date string) string {
+	if len(date) > len("2006-01-02") {
+		return date[:len("2006-01-02")]
+	}
+	return date
+}
+
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// entryMetadata looks up normalized PDB IDs in the RCSB Data API at url.
+// Entries the API does not know are missing from the returned map.
+func (c *PDBClient) entryMetadata(url string, ids []string) (map[string]entryMetadata, error) {
+	metadata := make(map[string]entryMetadata)
+	for start := 0; start < len(ids); start += metadataBatchSize {
+		end := start + metadataBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		// The Data API knows entries by their upper case classic ID where
+		// there is one.
+		batch := make([]string, 0, end-start)
+		for _, id := range ids[start:end] {
+			if short, ok := classicPDBId(id); ok {
+				id = short
+			}
+			batch = append(batch, strings.ToUpper(id))
+		}
+
+		var entries []*graphQLEntry
+		err := c.withRetries(func() error {
+			var err error
+			entries, err = c.queryEntries(url, batch)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry != nil {
+				m := entry.metadata()
+				metadata[m.id] = m
+			}
+		}
+	}
+	return metadata, nil
+}
+
+func (c *PDBClient) queryEntries(url string, ids []string) ([]*graphQLEntry, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     entryMetadataQuery,
+		"variables": map[string]interface{}{"ids": ids},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{
+			url:        url,
+			statusCode: resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	var response graphQLResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("reading entry metadata: %w", err)
+	}
+	// Unknown IDs are simply left out of the entries. Errors only count when
+	// the query failed as a whole and no entries list came back.
+	if response.Data.Entries == nil && len(response.Errors) > 0 {
+		return nil, fmt.Errorf("entry metadata query failed: %s", response.Errors[0].Message)
+	}
+	return response.Data.Entries, nil
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testEntryJSON = `{
+  "rcsb_id": "4HHB",
+  "struct": {"title": "THE CRYSTAL STRUCTURE OF HUMAN DEOXYHAEMOGLOBIN AT 1.74 ANGSTROMS RESOLUTION"},
+  "exptl": [{"method": "X-RAY DIFFRACTION"}],
+  "refine": [{"ls_R_factor_R_free": null}, {"ls_R_factor_R_free": 0.215}],
+  "rcsb_accession_info": {"deposit_date": "1984-03-07T00:00:00Z", "initial_release_date": "1984-07-17T00:00:00Z"},
+  "rcsb_entry_info": {"resolution_combined": [1.74], "polymer_entity_count": 2, "deposited_polymer_entity_instance_count": 4},
+  "polymer_entities": [
+    {"rcsb_entity_source_organism": [{"scientific_name": "Homo sapiens"}]},
+    {"rcsb_entity_source_organism": [{"scientific_name": "Homo sapiens"}]}
+  ],
+  "nonpolymer_entities": [
+    {"nonpolymer_comp": {"chem_comp": {"id": "PO4"}}},
+    {"nonpolymer_comp": {"chem_comp": {"id": "HEM"}}}
+  ]
+}`
+
+func Test_graphQLEntry_metadata(t *testing.T) {
+	var entry graphQLEntry
+	if err := json.Unmarshal([]byte(testEntryJSON), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := entryMetadata{
+		id:          "4hhb",
+		title:       "THE CRYSTAL STRUCTURE OF HUMAN DEOXYHAEMOGLOBIN AT 1.74 ANGSTROMS RESOLUTION",
+		methods:     []string{"X-RAY DIFFRACTION"},
+		resolution:  1.74,
+		rFree:       0.215,
+		depositDate: "1984-03-07",
+		releaseDate: "1984-07-17",
+		organisms:   []string{"Homo sapiens"},
+		entities:    2,
+		chains:      4,
+		ligands:     []string{"HEM", "PO4"},
+	}
+	if result := entry.metadata(); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+
+	if result := (&graphQLEntry{RcsbID: "1ABC"}).metadata(); result.id != "1abc" || result.resolution != 0 || result.title != "" {
+		t.Errorf("Expected empty metadata for a bare entry, got %+v", result)
+	}
+}
+
+func Test_PDBClient_entryMetadata(t *testing.T) {
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query     string `json:"query"`
+			Variables struct {
+				IDs []string `json:"ids"`
+			} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !strings.Contains(request.Query, "entries(entry_ids: $ids)") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requested = request.Variables.IDs
+
+		var entries []string
+		for _, id := range request.Variables.IDs {
+			if id == "4HHB" {
+				entries = append(entries, testEntryJSON)
+			}
+		}
+		fmt.Fprintf(w, `{"data": {"entries": [%s]}}`, strings.Join(entries, ","))
+	}))
+	defer ts.Close()
+
+	client := &PDBClient{client: &http.Client{}}
+	metadata, err := client.entryMetadata(ts.URL, []string{"4hhb", "9zzz", "pdb_00001abc"})
+	if err != nil {
+		t.Fatalf("entryMetadata() returned error: %v", err)
+	}
+	if expected := []string{"4HHB", "9ZZZ", "1ABC"}; !equalStringSlices(requested, expected) {
+		t.Errorf("Expected request for %v, got %v", expected, requested)
+	}
+	if len(metadata) != 1 || metadata["4hhb"].chains != 4 {
+		t.Errorf("Expected metadata for 4hhb only, got %+v", metadata)
+	}
+}
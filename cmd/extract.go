@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+
+	"kirill/pkg/structure"
+
+	"github.com/spf13/cobra"
+)
+
+// chainSelections collects the chain selections of PDB ID tokens such as
+// 1abc_A and 2def_B:10-150 by entry. Tokens without a selection and invalid
+// IDs are left out; the latter are reported when they are downloaded.
+func chainSelections(tokens []string) (map[string][]structure.Selection, error) {
+	selections := make(map[string][]structure.Selection)
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		id, chain, err := parsePDBIdToken(token)
+		if err != nil || chain == "" {
+			continue
+		}
+		sel, err := structure.ParseSelection(chain)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", token, err)
+		}
+		if key := id + "_" + sel.String(); !seen[key] {
+			seen[key] = true
+			selections[id] = append(selections[id], sel)
+		}
+	}
+	return selections, nil
+}
+
+// selectionFilename names the file a selection is extracted to, e.g.
+// 1ABC_A.pdb or 2DEF_B_10-150.cif.
+func selectionFilename(base string, sel structure.Selection, format structure.Format) string {
+	name := base + "_" + sel.Chain
+	if sel.Range {
+		name += "_" + strconv.Itoa(sel.Start) + "-" + strconv.Itoa(sel.End)
+	}
+	return name + format.Extension()
+}
+
+// extractSelections writes every selection of the structure in filename to a
+// file of its own in outputPath, in the given format or that of the input if
+// it is empty. It returns the names of the written files; selections that
+// fail do not stop the others.
+func extractSelections(filename, outputPath string, selections []structure.Selection, format structure.Format) ([]string, error) {
+	s, inputFormat, err := structure.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = inputFormat
+	}
+
+	var written []string
+	var errs []error
+	for _, sel := range selections {
+		output := path.Join(outputPath, selectionFilename(structureBaseName(filename), sel, format))
+		if err := writeSelection(s, sel, output, format); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", path.Base(filename), sel, err))
+			continue
+		}
+		written = append(written, output)
+	}
+	return written, errors.Join(errs...)
+}
+
+func writeSelection(s *structure.Structure, sel structure.Selection, output string, format structure.Format) error {
+	extracted, err := structure.Extract(s, sel)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := structure.Write(file, extracted, format); err != nil {
+		file.Close()
+		os.Remove(output)
+		return err
+	}
+	return file.Close()
+}
+
+// extractEntry extracts the selections of a downloaded entry, from every
+// assembly file if there are several. Failures are reported with
+// statusExtractFailed.
+func (c *PDBClient) extractEntry(result fetchResult, outputPath string) fetchResult {
+	selections := c.selections[result.id]
+	if result.content != contentCoords || len(selections) == 0 {
+		return result
+	}
+
+	var errs []error
+	for _, filename := range result.filenames {
+		written, err := extractSelections(filename, outputPath, selections, "")
+		result.extracted = append(result.extracted, written...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		result.status = statusExtractFailed
+		result.err = errors.Join(errs...)
+	}
+	return result
+}
+
+var extractCmd = &cobra.Command{
+	Use:   "extract [structure files]",
+	Short: "Write chains or residue ranges of structures to files of their own",
+	Long: `extract writes selected chains, or residue ranges of them, from PDB or mmCIF
+files to separate files in the output directory. Selections are given with
+--select as a chain ID such as A, or a chain with an inclusive range of
+residue numbers such as B:10-150. Output files are named after the input file
+and the selection, e.g. 1ABC_A.pdb or 1ABC_B_10-150.pdb, and keep the input
+format unless --to is given. Ligands and waters of a chain are included when
+they are in the range.
+
+Example usage:
+
+1. Extract chain A of an entry:
+   kirill extract 1ABC.pdb --select A
+
+2. Extract two domains of chain B from several entries as mmCIF:
+   kirill extract structures/*.pdb --select B:1-120,B:121-250 --to cif -o domains
+
+fetchpdb --extract does the same for chain suffixes in its input, so that
+1abc_A or 2def_B:10-150 are extracted right after downloading.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputPath, _ := cmd.Flags().GetString("output")
+		selectValues, _ := cmd.Flags().GetStringSlice("select")
+		to, _ := cmd.Flags().GetString("to")
+
+		var logFile *os.File
+		var err error
+
+		logPath := path.Join(outputPath, "extract")
+		logger, logFile, err = getLogger(logPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		logger.Println(getCommandLine())
+
+		var selections []structure.Selection
+		for _, value := range selectValues {
+			sel, err := structure.ParseSelection(value)
+			if err != nil {
+				logger.Fatalln(err)
+			}
+			selections = append(selections, sel)
+		}
+		var format structure.Format
+		if to != "" {
+			if format, err = structure.ParseFormat(to); err != nil {
+				logger.Fatalln(err)
+			}
+		}
+
+		failed := 0
+		for _, input := range args {
+			written, err := extractSelections(input, outputPath, selections, format)
+			for _, filename := range written {
+				logger.Printf("Extracted %s", filename)
+			}
+			if err != nil {
+				logger.Println(err)
+				failed++
+			}
+		}
+		logger.Printf("Extracted selections from %d of %d files", len(args)-failed, len(args))
+
+		if failed > 0 {
+			logFile.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(extractCmd)
+
+	extractCmd.Flags().StringP("output", "o", ".", "Output directory")
+	extractCmd.Flags().StringSliceP("select", "s", nil, "Chain or residue range to extract, such as A or B:10-150; repeat for several")
+	extractCmd.Flags().StringP("to", "t", "", "Output format: pdb or cif; the input format by default")
+	extractCmd.MarkFlagRequired("select")
+}
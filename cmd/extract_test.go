@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"kirill/pkg/structure"
+)
+
+const testExtractPDB = `HEADER    TEST                                    01-JAN-00   1ABC              
+ATOM      1  CA  GLY A   1       1.000   2.000   3.000  1.00 10.00           C  
+ATOM      2  CA  ALA A   2       2.000   2.000   3.000  1.00 10.00           C  
+ATOM      3  CA  SER A   3       3.000   2.000   3.000  1.00 10.00           C  
+ATOM      4  CA  LYS B   1       4.000   2.000   3.000  1.00 10.00           C  
+END
+`
+
+func Test_chainSelections(t *testing.T) {
+	selections, err := chainSelections([]string{"1abc_A", "1ABC_B:2-3", "1abc_A", "2def", "bogus_A", "pdb_00001abc_C"})
+	if err != nil {
+		t.Fatalf("chainSelections() returned error: %v", err)
+	}
+
+	var got []string
+	for _, sel := range selections["1abc"] {
+		got = append(got, sel.String())
+	}
+	if expected := []string{"A", "B:2-3", "C"}; !equalStringSlices(got, expected) || len(selections) != 1 {
+		t.Errorf("Expected selections %v for 1abc only, got %v", expected, selections)
+	}
+
+	if _, err := chainSelections([]string{"1abc_B:10"}); err == nil {
+		t.Errorf("Expected an error for an invalid residue range")
+	}
+}
+
+func Test_selectionFilename(t *testing.T) {
+	testCases := []struct {
+		selection structure.Selection
+		format    structure.Format
+		expected  string
+	}{
+		{structure.Selection{Chain: "A"}, structure.FormatPDB, "1ABC_A.pdb"},
+		{structure.Selection{Chain: "B", Start: 10, End: 150, Range: true}, structure.FormatCIF, "1ABC_B_10-150.cif"},
+		{structure.Selection{Chain: "C", Start: -5, End: 20, Range: true}, structure.FormatPDB, "1ABC_C_-5-20.pdb"},
+	}
+
+	for _, tc := range testCases {
+		if filename := selectionFilename("1ABC", tc.selection, tc.format); filename != tc.expected {
+			t.Errorf("selectionFilename(%s): expected %q, got %q", tc.selection, tc.expected, filename)
+		}
+	}
+}
+
+func Test_extractSelections(t *testing.T) {
+	dir := t.TempDir()
+	input := path.Join(dir, "1ABC.pdb")
+	if err := ioutil.WriteFile(input, []byte(testExtractPDB), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	selections := []structure.Selection{{Chain: "A", Start: 2, End: 3, Range: true}, {Chain: "Z"}, {Chain: "B"}}
+	written, err := extractSelections(input, dir, selections, "")
+	if err == nil || !strings.Contains(err.Error(), "chain Z not found") {
+		t.Errorf("Expected an error for chain Z, got %v", err)
+	}
+	expected := []string{path.Join(dir, "1ABC_A_2-3.pdb"), path.Join(dir, "1ABC_B.pdb")}
+	if !equalStringSlices(written, expected) {
+		t.Fatalf("Expected %v to be written, got %v", expected, written)
+	}
+
+	s, _, err := structure.ReadFile(written[0])
+	if err != nil {
+		t.Fatalf("ReadFile() returned error: %v", err)
+	}
+	if atoms := s.Models[0].Atoms(); len(atoms) != 2 || atoms[0].X != 2 || len(s.Models[0].Chains) != 1 {
+		t.Errorf("Expected residues 2-3 of chain A, got %d atoms", len(atoms))
+	}
+	if _, err := os.Stat(path.Join(dir, "1ABC_Z.pdb")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file for the missing chain")
+	}
+}
+
+func Test_fetchPDB_extract(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write([]byte(testExtractPDB))
+	}))
+	defer ts.Close()
+
+	logger = log.New(ioutil.Discard, "", 0)
+	selections, err := chainSelections([]string{"1abc_A", "1abc_B", "2def_Z"})
+	if err != nil {
+		t.Fatalf("chainSelections() returned error: %v", err)
+	}
+	client := &PDBClient{
+		mirrors:    testMirrors(ts),
+		client:     &http.Client{},
+		selections: selections,
+	}
+
+	outputPath := t.TempDir()
+	results := fetchPDB([]string{"1abc_A", "2def_Z", "3ghi"}, outputPath, client, 1)
+
+	if results[0].status != statusOK || !equalStringSlices(results[0].extracted, []string{
+		path.Join(outputPath, "1ABC_A.pdb"), path.Join(outputPath, "1ABC_B.pdb"),
+	}) {
+		t.Errorf("Expected chains A and B of 1abc to be extracted, got %s %v (%v)", results[0].status, results[0].extracted, results[0].err)
+	}
+	if results[1].status != statusExtractFailed || !equalStringSlices(results[1].filenames, []string{path.Join(outputPath, "2DEF.pdb")}) {
+		t.Errorf("Expected the extraction of 2def to fail after the download, got %s (%v)", results[1].status, results[1].err)
+	}
+	if results[2].status != statusOK || len(results[2].extracted) != 0 {
+		t.Errorf("Expected 3ghi to be downloaded without extraction, got %s %v", results[2].status, results[2].extracted)
+	}
+	if countFailed(results) != 1 {
+		t.Errorf("Expected the failed extraction to count as failed")
+	}
+}
+
+func Test_fetchPDB_extract_assemblies(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path.Base(r.URL.Path) {
+		case "1abc.pdb1.gz", "1abc.pdb2.gz":
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			gz.Write([]byte(testExtractPDB))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	logger = log.New(ioutil.Discard, "", 0)
+	selections, err := chainSelections([]string{"1abc_B"})
+	if err != nil {
+		t.Fatalf("chainSelections() returned error: %v", err)
+	}
+	client := &PDBClient{
+		mirrors:    testMirrors(ts),
+		client:     &http.Client{},
+		assembly:   allAssemblies,
+		selections: selections,
+	}
+
+	// A comma in the output directory must not split the assembly files.
+	outputPath := path.Join(t.TempDir(), "run 1,2")
+	if err := os.Mkdir(outputPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	results := fetchPDB([]string{"1abc_B"}, outputPath, client, 1)
+
+	r := results[0]
+	if r.status != statusOK || len(r.filenames) != 2 {
+		t.Fatalf("Expected two assemblies, got %s %v (%v)", r.status, r.filenames, r.err)
+	}
+	if !equalStringSlices(r.extracted, []string{
+		path.Join(outputPath, "1ABC-assembly1_B.pdb"), path.Join(outputPath, "1ABC-assembly2_B.pdb"),
+	}) {
+		t.Errorf("Expected chain B of both assemblies to be extracted, got %v", r.extracted)
+	}
+}
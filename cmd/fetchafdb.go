@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// afdbModelVersion is the current release of AlphaFold DB model files.
+const afdbModelVersion = 4
+
+// contentPAE selects the predicted aligned error of an AlphaFold model.
+const contentPAE = "pae"
+
+// afdbMirror serves AlphaFold DB files by UniProt accession. Local files keep
+// the AF- prefix, so only the accession goes into the template.
+var afdbMirror = pdbMirror{name: "afdb", template: "https://alphafold.ebi.ac.uk/files/AF-{ID}{ext}"}
+
+// uniProtAccessionPattern is the accession format documented by UniProt.
+var uniProtAccessionPattern = regexp.MustCompile(`^([OPQ][0-9][A-Z0-9]{3}[0-9]|[A-NR-Z][0-9]([A-Z][A-Z0-9]{2}[0-9]){1,2})$`)
+
+// normalizeUniProtAccession validates a UniProt accession and returns it in
+// upper case. Isoform suffixes such as -2 are rejected, since AlphaFold DB
+// only has models for canonical sequences.
+func normalizeUniProtAccession(token string) (string, error) {
+	accession := strings.ToUpper(strings.TrimSpace(token))
+	if !uniProtAccessionPattern.MatchString(accession) {
+		return "", fmt.Errorf("invalid UniProt accession: %q", token)
+	}
+	return accession, nil
+}
+
+// afdbFormats returns the coordinate format and the auxiliary formats of
+// AlphaFold DB model files of the given version. Only the first fragment (F1)
+// is downloaded; longer proteins are split only in the bulk archives.
+func afdbFormats(formatName string, version int) (structureFormat, []structureFormat, error) {
+	if version < 1 {
+		return structureFormat{}, nil, fmt.Errorf("invalid model version: %d", version)
+	}
+
+	format, err := parseStructureFormat(formatName)
+	if err != nil {
+		return structureFormat{}, nil, err
+	}
+	if format.name == formatXML.name {
+		return structureFormat{}, nil, fmt.Errorf("AlphaFold DB models are not available as %s", format.name)
+	}
+
+	model := fmt.Sprintf("-F1-model_v%d%s", version, format.localExtension)
+	coords := structureFormat{format.name, model, model, "", format.validate}
+
+	pae := fmt.Sprintf("-F1-predicted_aligned_error_v%d.json", version)
+	auxiliary := []structureFormat{{contentPAE, pae, pae, "", validateJSONContent}}
+	return coords, auxiliary, nil
+}
+
+// parseAFDBContents reads the --content option of fetchafdb.
+func parseAFDBContents(values []string, auxiliary []structureFormat) ([]string, error) {
+	var contents []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		known := value == contentCoords
+		for _, format := range auxiliary {
+			known = known || format.name == value
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown content type: %s", value)
+		}
+		if !seen[value] {
+			seen[value] = true
+			contents = append(contents, value)
+		}
+	}
+	if len(contents) == 0 {
+		contents = []string{contentCoords}
+	}
+	return contents, nil
+}
+
+// resolveAFDBMirrors picks the AlphaFold DB mirrors given with --mirror,
+// either "afdb" or URL templates, in failover order.
+func resolveAFDBMirrors(values []string) ([]pdbMirror, error) {
+	if len(values) == 0 {
+		return []pdbMirror{afdbMirror}, nil
+	}
+
+	mirrors := make([]pdbMirror, 0, len(values))
+	for _, value := range values {
+		if strings.ToLower(value) == afdbMirror.name {
+			mirrors = append(mirrors, afdbMirror)
+			continue
+		}
+		if err := validateMirrorTemplate(value); err != nil {
+			return nil, fmt.Errorf("unknown mirror %q: %w", value, err)
+		}
+		mirrors = append(mirrors, pdbMirror{name: value, template: value})
+	}
+	return mirrors, nil
+}
+
+var fetchafdbCmd = &cobra.Command{
+	Use:   "fetchafdb [UniProt accessions or input file]",
+	Short: "Fetch predicted structures from the AlphaFold Protein Structure Database",
+	Long: `fetchafdb downloads AlphaFold models for proteins given by UniProt accession.
+Accessions are read like PDB IDs in fetchpdb: as arguments, from input files,
+from "-" for standard input, or from a CSV/TSV column with --column.
+
+Example usage:
+
+1. Download models for a list of UniProt accessions:
+   kirill fetchafdb P69905 P68871
+
+2. Download mmCIF models and their predicted aligned error (PAE) from a file:
+   kirill fetchafdb accessions.txt --format cif --content coords,pae -o models
+
+3. Download models from an older release of the database:
+   kirill fetchafdb P69905 --model-version 3
+
+Files are named as in AlphaFold DB, e.g. AF-P69905-F1-model_v4.pdb and
+AF-P69905-F1-predicted_aligned_error_v4.json. Only the first fragment of a
+model is downloaded.
+
+Models are downloaded from https://alphafold.ebi.ac.uk by default. --mirror
+takes URL templates in which {ID} is replaced by the accession and {ext} by
+the rest of the file name, e.g. https://afdb.example.org/AF-{ID}{ext}.
+
+As with fetchpdb, the outcome for every accession is written to
+fetchafdb_report.tsv (or .json), downloads are recorded in
+fetchpdb_manifest.tsv and transient failures are retried.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputPath, _ := cmd.Flags().GetString("output")
+		column, _ := cmd.Flags().GetString("column")
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		formatName, _ := cmd.Flags().GetString("format")
+		contentValues, _ := cmd.Flags().GetStringSlice("content")
+		version, _ := cmd.Flags().GetInt("model-version")
+		mirrorValues, _ := cmd.Flags().GetStringSlice("mirror")
+		retries, _ := cmd.Flags().GetInt("retries")
+		backoff, _ := cmd.Flags().GetDuration("backoff")
+		skipExisting, _ := cmd.Flags().GetBool("skip-existing")
+		reportFormat, _ := cmd.Flags().GetString("report-format")
+
+		var logFile *os.File
+		var err error
+
+		logPath := path.Join(outputPath, "fetchafdb")
+		logger, logFile, err = getLogger(logPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		logger.Println(getCommandLine())
+
+		if err := validateReportFormat(reportFormat); err != nil {
+			logger.Fatalln(err)
+		}
+
+		format, auxiliary, err := afdbFormats(formatName, version)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		contents, err := parseAFDBContents(contentValues, auxiliary)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		mirrors, err := resolveAFDBMirrors(mirrorValues)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		logger.Printf("Using mirrors: %s", mirrorNames(mirrors))
+
+		manifest, err := openFetchManifest(outputPath)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		defer manifest.Close()
+
+		client := &PDBClient{
+			mirrors: mirrors,
+			client:  newHTTPClient(),
+
+			formats:      []structureFormat{format},
+			contents:     contents,
+			manifest:     manifest,
+			skipExisting: skipExisting,
+			normalizeID:  normalizeUniProtAccession,
+			auxiliary:    auxiliary,
+			filePrefix:   "AF-",
+			retries:      retries,
+			backoff:      backoff,
+		}
+
+		accessions, err := readIdTokens(args, column, os.Stdin, "UniProt accessions", normalizeUniProtAccession)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+
+		results := fetchPDB(accessions, outputPath, client, jobs)
+
+		reportPath, err := writeFetchReport(results, path.Join(outputPath, "fetchafdb_report"), reportFormat)
+		if err != nil {
+			logger.Println(err)
+		} else {
+			logger.Printf("Wrote report to %s", reportPath)
+		}
+
+		if err != nil || countFailed(results) > 0 {
+			manifest.Close()
+			logFile.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fetchafdbCmd)
+
+	fetchafdbCmd.Flags().StringP("output", "o", ".", "Output directory")
+	fetchafdbCmd.Flags().StringP("column", "c", "", "Read accessions from this column (name or 1-based index) of CSV/TSV input files")
+	fetchafdbCmd.Flags().IntP("jobs", "j", 4, "Number of parallel downloads")
+	fetchafdbCmd.Flags().StringP("format", "f", "pdb", "Model file format (pdb, cif or bcif)")
+	fetchafdbCmd.Flags().StringSliceP("content", "", []string{contentCoords}, "Files to download per model: coords, pae")
+	fetchafdbCmd.Flags().IntP("model-version", "", afdbModelVersion, "AlphaFold DB model version")
+	fetchafdbCmd.Flags().StringSliceP("mirror", "m", nil, "afdb or a URL template; repeat to set the failover order")
+	fetchafdbCmd.Flags().IntP("retries", "", 3, "Number of retries after a transient failure")
+	fetchafdbCmd.Flags().DurationP("backoff", "", time.Second, "Initial delay between retries, doubled on every attempt")
+	fetchafdbCmd.Flags().BoolP("skip-existing", "", false, "Skip models already recorded in the manifest")
+	fetchafdbCmd.Flags().StringP("report-format", "", "tsv", "Format of the per-accession fetch report (tsv or json)")
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+)
+
+func Test_normalizeUniProtAccession(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+		err      bool
+	}{
+		{input: "P69905", expected: "P69905"},
+		{input: "q9y6k9", expected: "Q9Y6K9"},
+		{input: " A0A024R161 ", expected: "A0A024R161"},
+		{input: "O00001", expected: "O00001"},
+		{input: "P6990", err: true},
+		{input: "P69905-2", err: true},
+		{input: "1abc", err: true},
+		{input: "A0A024R16", err: true},
+		{input: "", err: true},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("Test case %d", i+1), func(t *testing.T) {
+			result, err := normalizeUniProtAccession(tc.input)
+			if (err != nil) != tc.err {
+				t.Errorf("Expected error: %v, got: %v", tc.err, err)
+			}
+			if result != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func Test_resolveAFDBMirrors(t *testing.T) {
+	mirrors, err := resolveAFDBMirrors(nil)
+	if err != nil || len(mirrors) != 1 || mirrors[0].name != afdbMirror.name {
+		t.Errorf("Expected the afdb mirror by default, got %v, %v", mirrors, err)
+	}
+
+	mirrors, err = resolveAFDBMirrors([]string{"https://afdb.example.org/AF-{ID}{ext}", "AFDB"})
+	if err != nil || len(mirrors) != 2 || mirrors[1].name != afdbMirror.name {
+		t.Errorf("Expected a template and the afdb mirror, got %v, %v", mirrors, err)
+	}
+
+	if _, err := resolveAFDBMirrors([]string{"rcsb"}); err == nil {
+		t.Error("Expected error for a PDB mirror preset")
+	}
+}
+
+func Test_fetchAFDB(t *testing.T) {
+	// AlphaFold DB serves files uncompressed.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/AF-P69905-F1-model_v3.cif":
+			w.Write([]byte("data_AF-P69905-F1\n#\n"))
+		case "/AF-P69905-F1-predicted_aligned_error_v3.json":
+			w.Write([]byte(`[{"predicted_aligned_error":[[0,1],[1,0]]}]`))
+		case "/AF-P68871-F1-model_v3.cif":
+			w.Write([]byte("data_AF-P68871-F1\n#\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	format, auxiliary, err := afdbFormats("cif", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputPath := t.TempDir()
+	client := &PDBClient{
+		mirrors:     []pdbMirror{{name: "test", template: ts.URL + "/AF-{ID}{ext}"}},
+		client:      &http.Client{},
+		formats:     []structureFormat{format},
+		contents:    []string{contentCoords, contentPAE},
+		normalizeID: normalizeUniProtAccession,
+		auxiliary:   auxiliary,
+		filePrefix:  "AF-",
+	}
+
+	logger = log.New(ioutil.Discard, "", 0)
+
+	results := fetchPDB([]string{"p69905", "P68871", "1abc"}, outputPath, client, 2)
+
+	expected := []struct {
+		id      string
+		content string
+		status  fetchStatus
+		file    string
+	}{
+		{"P69905", contentCoords, statusOK, "AF-P69905-F1-model_v3.cif"},
+		{"P69905", contentPAE, statusOK, "AF-P69905-F1-predicted_aligned_error_v3.json"},
+		{"P68871", contentCoords, statusOK, "AF-P68871-F1-model_v3.cif"},
+		{"P68871", contentPAE, statusNotFound, ""},
+		{"1abc", contentCoords, statusInvalid, ""},
+		{"1abc", contentPAE, statusInvalid, ""},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, e := range expected {
+		r := results[i]
+		if r.id != e.id || r.content != e.content || r.status != e.status {
+			t.Errorf("Result %d: expected (%s, %s, %s), got (%s, %s, %s)", i, e.id, e.content, e.status, r.id, r.content, r.status)
+		}
+		if e.file != "" && !equalStringSlices(r.filenames, []string{path.Join(outputPath, e.file)}) {
+			t.Errorf("Result %d: expected file %s, got %v", i, e.file, r.filenames)
+		}
+	}
+
+	content, err := ioutil.ReadFile(path.Join(outputPath, "AF-P69905-F1-model_v3.cif"))
+	if err != nil || string(content) != "data_AF-P69905-F1\n#\n" {
+		t.Errorf("Expected model written as served, got %q, %v", content, err)
+	}
+}
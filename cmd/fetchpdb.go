@@ -1,126 +1,303 @@
 package cmd
 
 import (
-	"bufio"
-	"compress/gzip"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/url"
+	"io"
 	"os"
 	"path"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
-func validatePDBId(ids []string) ([]string, error) {
-	var res []string
+// Extended PDB IDs have the form pdb_00001abc. Every classic four-character
+// ID maps to an extended ID by padding it with zeros.
+const (
+	extendedPDBIdPrefix  = "pdb_"
+	extendedPDBIdPadding = "0000"
+	extendedPDBIdLength  = 12
+)
+
+func isClassicPDBId(id string) bool {
 	isNotDigit := func(c rune) bool { return c < '0' || c > '9' }
+	return len(id) == 4 && strings.IndexFunc(id, isNotDigit) != -1
+}
 
-	for i, val := range ids {
-		if len(val) != 4 || strings.IndexFunc(val, isNotDigit) == -1 {
-			return nil, fmt.Errorf("error in pdb %d: %s", i+1, val)
+func isExtendedPDBId(id string) bool {
+	isNotAlphanumeric := func(c rune) bool {
+		return (c < '0' || c > '9') && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z')
+	}
+	return len(id) == extendedPDBIdLength &&
+		strings.EqualFold(id[:len(extendedPDBIdPrefix)], extendedPDBIdPrefix) &&
+		strings.IndexFunc(id[len(extendedPDBIdPrefix):], isNotAlphanumeric) == -1
+}
+
+// normalizePDBId validates a classic or extended PDB ID and returns it in
+// lower case. Extended IDs that correspond to a classic ID are shortened, so
+// both spellings of the same entry normalize to the same value and keep
+// working with archive file names that only exist in the classic form.
+func normalizePDBId(id string) (string, error) {
+	id = strings.ToLower(id)
+	if isClassicPDBId(id) {
+		return id, nil
+	}
+	if isExtendedPDBId(id) {
+		if short, ok := classicPDBId(id); ok {
+			return short, nil
 		}
-		res = append(res, strings.ToLower(val))
+		return id, nil
+	}
+	return "", fmt.Errorf("invalid PDB ID: %q", id)
+}
 
+// extendedPDBId maps a normalized PDB ID to its extended form.
+func extendedPDBId(id string) string {
+	if isExtendedPDBId(id) {
+		return id
 	}
-	return res, nil
+	return extendedPDBIdPrefix + extendedPDBIdPadding + id
 }
 
-func readPDBIdList(input []string) ([]string, error) {
-	var ids []string
-	_, err := os.Stat(input[0])
-	if os.IsNotExist(err) {
-		logger.Println("Assuming input is a list of PDB IDs")
-		ids, err := validatePDBId(input)
-		if err != nil {
-			return nil, err
-		}
-		return ids, nil
+// classicPDBId maps a normalized PDB ID to its classic four-character form,
+// if there is one.
+func classicPDBId(id string) (string, bool) {
+	if isClassicPDBId(id) {
+		return id, true
+	}
+	prefix := extendedPDBIdPrefix + extendedPDBIdPadding
+	if isExtendedPDBId(id) && strings.HasPrefix(id, prefix) && isClassicPDBId(id[len(prefix):]) {
+		return id[len(prefix):], true
 	}
-	logger.Println("Assuming input as a file with a list of PDB IDs")
+	return "", false
+}
+
+// readPDBIdTokens returns the raw, unvalidated PDB ID tokens given as
+// arguments. Each argument is either "-" for standard input, the name of an
+// existing file, or one or more literal IDs. Duplicate entries are removed.
+func readPDBIdTokens(input []string, column string, stdin io.Reader) ([]string, error) {
+	return readIdTokens(input, column, stdin, "PDB IDs", normalizePDBIdToken)
+}
 
-	file, err := os.Open(input[0])
+// readIdTokens is readPDBIdTokens for other kinds of IDs, named by noun in
+// the log and validated by normalize to find duplicates.
+func readIdTokens(input []string, column string, stdin io.Reader, noun string, normalize func(string) (string, error)) ([]string, error) {
+	tokens, err := collectIdTokens(input, column, stdin, noun)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		ids = append(ids, scanner.Text())
+	tokens, duplicates := dedupeIdTokens(tokens, normalize)
+	if duplicates > 0 {
+		logger.Printf("Removed %d duplicate %s", duplicates, noun)
 	}
-	ids, err = validatePDBId(ids)
-	if err != nil {
-		return nil, err
+	return tokens, nil
+}
+
+// collectIdTokens is readIdTokens without removing duplicates, for callers
+// that need every token, such as those selecting different chains of the
+// same entry.
+func collectIdTokens(input []string, column string, stdin io.Reader, noun string) ([]string, error) {
+	var tokens []string
+	for _, arg := range input {
+		if arg == "-" {
+			logger.Printf("Reading %s from standard input", noun)
+			ids, err := parseIdList(stdin, column)
+			if err != nil {
+				return nil, fmt.Errorf("standard input: %w", err)
+			}
+			tokens = append(tokens, ids...)
+			continue
+		}
+
+		if _, err := os.Stat(arg); os.IsNotExist(err) {
+			tokens = append(tokens, splitIdTokens(arg)...)
+			continue
+		}
+
+		logger.Printf("Reading %s from file %s", noun, arg)
+		file, err := os.Open(arg)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := parseIdList(file, column)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arg, err)
+		}
+		tokens = append(tokens, ids...)
 	}
-	return ids, nil
+	return tokens, nil
 }
 
-type PDBClient struct {
-	scheme string
-	host   string
-	path   string
-	client *http.Client
+// fetchTask is one file to download: a raw ID token and a content type.
+type fetchTask struct {
+	input   string
+	content string
 }
 
-func (c *PDBClient) fetch(id string, outputPath string) error {
-	url := url.URL{
-		Scheme: c.scheme,
-		Host:   c.host,
-		Path:   path.Join(c.path, id+".pdb.gz"),
+// fetchTasks pairs every ID with every content type requested from client.
+func fetchTasks(ids []string, client *PDBClient) []fetchTask {
+	contents := client.contents
+	if len(contents) == 0 {
+		contents = []string{contentCoords}
 	}
-	filename := strings.ToUpper(id) + ".pdb"
-	filename = path.Join(outputPath, filename)
 
-	resp, err := c.client.Get(url.String())
-	if err != nil {
-		return err
+	tasks := make([]fetchTask, 0, len(ids)*len(contents))
+	for _, id := range ids {
+		for _, content := range contents {
+			tasks = append(tasks, fetchTask{input: id, content: content})
+		}
 	}
-	defer resp.Body.Close()
+	return tasks
+}
+
+// fetchID validates a single raw ID and downloads it, recording the outcome
+// instead of failing so that one bad entry does not abort the whole batch.
+func fetchID(index int, task fetchTask, outputPath string, client *PDBClient) fetchResult {
+	result := fetchResult{index: index, id: task.input, content: task.content}
 
-	body, err := gzip.NewReader(resp.Body)
+	id, err := client.normalize(task.input)
 	if err != nil {
-		return err
+		result.status = statusInvalid
+		result.err = err
+		return result
 	}
-	defer body.Close()
+	result.id = id
 
-	buf, err := ioutil.ReadAll(body)
-	if err != nil {
-		return err
+	if reason, ok := client.rejected[id]; ok {
+		result.status = statusFiltered
+		result.err = errors.New(reason)
+		return result
 	}
-	if err := ioutil.WriteFile(filename, buf, 0644); err != nil {
-		return err
+
+	if filename, ok := client.existingContent(id, task.content, outputPath); ok {
+		result.filenames = []string{filename}
+		result.status = statusSkipped
+		return client.extractEntry(result, outputPath)
 	}
 
-	logger.Printf("Loaded %s to %s", id, filename)
+	result.filenames, result.err = client.fetchContent(id, task.content, outputPath)
+	result.status = classifyFetchError(result.err)
+	if result.status == statusNotFound || result.status == statusCorrupt {
+		result = client.handleObsolete(result, outputPath)
+	}
+	if result.status == statusOK {
+		result = client.extractEntry(result, outputPath)
+	}
+	return result
+}
 
-	return nil
+// fetchAll downloads tasks using a pool of jobs workers. Results are sent to
+// the returned channel as soon as they are ready, in no particular order.
+func fetchAll(tasks []fetchTask, outputPath string, client *PDBClient, jobs int) <-chan fetchResult {
+	indexes := make(chan int)
+	results := make(chan fetchResult)
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results <- fetchID(i, tasks[i], outputPath, client)
+			}
+		}()
+	}
+
+	go func() {
+		for i := range tasks {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
 }
 
-func fetchPDB(input []string, outputPath string, client *PDBClient) {
-	ids, err := readPDBIdList(input)
-	if err != nil {
-		logger.Fatalln(err)
-		os.Exit(1)
+// logExtracted logs the files written for the chain selections of r.
+func logExtracted(n, total int, r fetchResult) {
+	if len(r.extracted) > 0 {
+		logger.Printf("[%d/%d] Extracted %s", n, total, strings.Join(r.extracted, ", "))
 	}
+}
 
-	for _, id := range ids {
-		if err := client.fetch(id, outputPath); err != nil {
-			if err != nil {
-				logger.Fatalln(err)
-				os.Exit(1)
+func fetchPDB(ids []string, outputPath string, client *PDBClient, jobs int) []fetchResult {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	start := time.Now()
+	tasks := fetchTasks(ids, client)
+	logger.Printf("Fetching %d files for %d entries with %d workers", len(tasks), len(ids), jobs)
+
+	// Workers finish out of order, so results are held back until every
+	// preceding task has been logged. This keeps the log in input order.
+	results := make([]fetchResult, len(tasks))
+	pending := make(map[int]fetchResult)
+	next := 0
+	for result := range fetchAll(tasks, outputPath, client, jobs) {
+		pending[result.index] = result
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			results[next] = r
+			next++
+
+			name := r.id
+			if r.content != contentCoords {
+				name += " " + r.content
+			}
+			if r.status == statusFiltered || r.status == statusObsolete {
+				logger.Printf("[%d/%d] Skipped %s: %v", next, len(tasks), name, r.err)
+				continue
+			}
+			if r.err != nil {
+				logger.Printf("[%d/%d] Failed %s (%s): %v", next, len(tasks), name, r.status, r.err)
+				logExtracted(next, len(tasks), r)
+				continue
 			}
+			if r.status == statusSkipped {
+				logger.Printf("[%d/%d] Skipped %s, already downloaded to %s", next, len(tasks), name, strings.Join(r.filenames, ", "))
+				logExtracted(next, len(tasks), r)
+				continue
+			}
+			if r.replacedBy != "" {
+				logger.Printf("[%d/%d] %s is obsolete, following it to %s", next, len(tasks), name, r.replacedBy)
+			} else if r.obsolete {
+				logger.Printf("[%d/%d] %s is obsolete, downloaded from the archive of obsolete entries", next, len(tasks), name)
+			}
+			if r.content == contentCoords && client.assembly == allAssemblies {
+				logger.Printf("[%d/%d] %s has %d biological assemblies", next, len(tasks), name, len(r.filenames))
+			}
+			logger.Printf("[%d/%d] Loaded %s to %s", next, len(tasks), name, strings.Join(r.filenames, ", "))
+			logExtracted(next, len(tasks), r)
 		}
 	}
+
+	logger.Printf("Fetched %s in %s", summarizeFetchResults(results), time.Since(start).Round(time.Millisecond))
+
+	return results
 }
 
 var fetchpdbCmd = &cobra.Command{
 	Use:   "fetchpdb [PDB IDs or input file]",
 	Short: "Fetch protein structures from the Protein Data Bank",
 	Long: `fetchpdb is a command-line tool to download protein structures from the Protein Data Bank (PDB).
-It accepts a list of PDB IDs or an input file containing PDB IDs, one per line.
+It accepts any mix of PDB IDs, input files containing PDB IDs and "-" for
+standard input. IDs in files may be separated by newlines, spaces or commas,
+and everything after a # is ignored. With --column, input files are read as
+CSV or TSV tables and IDs are taken from the given column. Both classic (1abc)
+and extended (pdb_00001abc) IDs are accepted, and duplicate entries are
+downloaded once. Chain suffixes such as 1abc_A or 2def_B:10-150 are ignored
+unless --extract is given.
 
 Example usage:
 
@@ -131,10 +308,127 @@ Example usage:
    kirill fetchpdb pdb_ids.txt
 
 3. Download structures from an input file and save them to a specific output directory:
-   kirill fetchpdb pdb_ids.txt -o /path/to/output`,
-	Args: cobra.MinimumNArgs(1),
+   kirill fetchpdb pdb_ids.txt -o /path/to/output
+
+4. Download structures with 8 parallel workers:
+   kirill fetchpdb pdb_ids.txt -j 8
+
+5. Mix files and IDs, or read IDs from the "entry" column of a CSV export:
+   kirill fetchpdb pdb_ids.txt 1abc 2def_B
+   kirill fetchpdb rcsb_export.csv --column entry
+   cut -f1 hits.tsv | kirill fetchpdb -
+
+6. Download mmCIF files instead of legacy PDB files:
+   kirill fetchpdb 4v6x --format cif
+
+7. Download legacy PDB files, falling back to mmCIF for large structures:
+   kirill fetchpdb pdb_ids.txt --fallback
+
+8. Download every biological assembly as mmCIF (1ABC-assembly1.cif, ...):
+   kirill fetchpdb pdb_ids.txt --format cif --assembly all
+
+9. Download coordinates, structure factors and validation reports:
+   kirill fetchpdb pdb_ids.txt --content coords,sf,validation
+
+10. Download every entry with a chain covering at least 100 residues of a protein:
+    kirill fetchpdb --uniprot P69905 P68871 --min-coverage 100
+
+11. Download only X-ray structures at 2.5 Å or better:
+    kirill fetchpdb pdb_ids.txt --method xray --max-resolution 2.5
+
+12. Download entries and extract the listed chains and residue ranges:
+    kirill fetchpdb 1abc_A 2def_B:10-150 --extract
+
+Besides coordinates (coords), --content can select structure factors (sf,
+saved as 1ABC-sf.cif), NMR restraints (mr, 1ABC.mr), NMR chemical shifts (cs,
+1ABC_cs.str), both NMR files (nmr) and wwPDB validation reports (validation for
+the PDF, 1ABC_validation.pdf, or validation-xml for the XML).
+
+With --uniprot, the inputs are UniProt accessions. They are mapped to PDB
+entries and chains with the SIFTS table pdb_chain_uniprot.tsv, which is
+downloaded from EBI to the output directory on first use and reused until it is
+older than --sifts-max-age, a week by default, as SIFTS is updated weekly;
+--sifts-max-age 0 downloads it every time. --sifts points at another copy,
+local or remote. --min-coverage drops chains
+that align to fewer residues of the UniProt sequence.
+
+--max-resolution, --method and --min-release-date look up the metadata of all
+entries in the RCSB Data API before downloading and skip those that do not
+match, logging the reason. --method takes the same values as in searchpdb.
+Entries without a resolution, such as NMR structures, do not pass
+--max-resolution. Skipped entries are reported as filtered.
+
+With --extract, the chains and residue ranges selected by suffixes such as
+1abc_A and 2def_B:10-150 are written to files of their own next to the entry,
+e.g. 1ABC_A.pdb and 2DEF_B_10-150.pdb, as with the extract command. Entries
+listed with several suffixes are downloaded once. With --uniprot, the chains
+mapped to the accessions are extracted.
+
+Invalid IDs and failed downloads do not stop the run. The outcome for every ID
+and content type is written to fetchpdb_report.tsv (or .json with --report-format json) in the
+output directory, and the exit code is non-zero if any ID failed.
+
+Entries with files that cannot be downloaded are checked against the RCSB
+holdings, once per entry. For obsolete entries, --obsolete selects what
+happens to every --content type: skip (the default) reports them as obsolete
+along with the entry that replaced them, fetch-archive downloads the
+coordinates from the archive of obsolete entries and reports the other
+contents as obsolete, and follow downloads the superseding entry instead and
+records it in the report's replaced_by column.
+
+Server errors, rate limiting and dropped connections are retried up to
+--retries times with exponential backoff starting at --backoff, honoring the
+Retry-After header when the server sends one.
+
+Entries are downloaded from RCSB by default. Use --mirror to pick one or more
+of the presets rcsb, pdbe, pdbj and wwpdb, or a URL template such as
+https://pdb.example.org/{hash}/{id}{ext}; mirrors are tried in the given order
+until one succeeds. Templates may use {id}, {ID}, {hash}, {ext} and
+{archive_path}, the path of a file below pub/pdb/ in the wwPDB archive, and
+file:// URLs point at a local copy of the archive.
+Default mirrors can also be listed, one per line, in the --mirror-config file
+as a preset name or as "name template".
+
+Files are written atomically and recorded with their size and SHA-256 checksum
+in fetchpdb_manifest.tsv in the output directory. With --skip-existing, entries
+already listed in the manifest are not downloaded again, so an interrupted run
+can simply be restarted. With --verify, no downloads are made; instead every
+file in the manifest is checked against its recorded checksum and the result
+is written to fetchpdb_verify.tsv.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if verify, _ := cmd.Flags().GetBool("verify"); verify {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		outputPath, _ := cmd.Flags().GetString("output")
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		reportFormat, _ := cmd.Flags().GetString("report-format")
+		retries, _ := cmd.Flags().GetInt("retries")
+		backoff, _ := cmd.Flags().GetDuration("backoff")
+		formatName, _ := cmd.Flags().GetString("format")
+		fallback, _ := cmd.Flags().GetBool("fallback")
+		skipExisting, _ := cmd.Flags().GetBool("skip-existing")
+		verify, _ := cmd.Flags().GetBool("verify")
+		progressInterval, _ := cmd.Flags().GetDuration("progress")
+		mirrorValues, _ := cmd.Flags().GetStringSlice("mirror")
+		mirrorConfig, _ := cmd.Flags().GetString("mirror-config")
+		extendedNames, _ := cmd.Flags().GetBool("extended-names")
+		column, _ := cmd.Flags().GetString("column")
+		assemblyValue, _ := cmd.Flags().GetString("assembly")
+		contentValues, _ := cmd.Flags().GetStringSlice("content")
+		uniprot, _ := cmd.Flags().GetBool("uniprot")
+		siftsSource, _ := cmd.Flags().GetString("sifts")
+		siftsMaxAge, _ := cmd.Flags().GetDuration("sifts-max-age")
+		minCoverage, _ := cmd.Flags().GetInt("min-coverage")
+		obsoleteValue, _ := cmd.Flags().GetString("obsolete")
+		extract, _ := cmd.Flags().GetBool("extract")
+
+		var filter entryFilter
+		filter.maxResolution, _ = cmd.Flags().GetFloat64("max-resolution")
+		filter.methods, _ = cmd.Flags().GetStringSlice("method")
+		filter.minReleaseDate, _ = cmd.Flags().GetString("min-release-date")
 
 		var logFile *os.File
 		var err error
@@ -149,14 +443,135 @@ Example usage:
 
 		logger.Println(getCommandLine())
 
+		if err := validateReportFormat(reportFormat); err != nil {
+			logger.Fatalln(err)
+		}
+
+		manifest, err := openFetchManifest(outputPath)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		defer manifest.Close()
+
+		if verify {
+			results := verifyManifest(manifest)
+			logger.Printf("Verified %s", summarizeFetchResults(results))
+			reportPath, err := writeFetchReport(results, path.Join(outputPath, "fetchpdb_verify"), reportFormat)
+			if err != nil {
+				logger.Println(err)
+			} else {
+				logger.Printf("Wrote report to %s", reportPath)
+			}
+			if err != nil || countFailed(results) > 0 {
+				manifest.Close()
+				logFile.Close()
+				os.Exit(1)
+			}
+			return
+		}
+
+		format, err := parseStructureFormat(formatName)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		formats := []structureFormat{format}
+		if fallback && format.name == formatPDB.name {
+			formats = append(formats, formatCIF)
+		}
+		assembly, err := parseAssembly(assemblyValue)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		if assembly != 0 && format.name != formatPDB.name && format.name != formatCIF.name {
+			logger.Fatalf("biological assemblies are only available in pdb and cif format, not %s", format.name)
+		}
+		if extract && format.name != formatPDB.name && format.name != formatCIF.name {
+			logger.Fatalf("chains can only be extracted from pdb and cif files, not %s", format.name)
+		}
+		contents, err := parseContents(contentValues)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		if err := filter.validate(); err != nil {
+			logger.Fatalln(err)
+		}
+		obsoletePolicy, err := parseObsoletePolicy(obsoleteValue)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+
+		mirrors, err := resolveMirrors(mirrorValues, mirrorConfig, cmd.Flags().Changed("mirror-config"))
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		logger.Printf("Using mirrors: %s", mirrorNames(mirrors))
+
 		client := &PDBClient{
-			scheme: "https",
-			host:   "files.rcsb.org",
-			path:   "download",
-			client: &http.Client{},
+			mirrors: mirrors,
+			client:  newHTTPClient(),
+
+			formats:       formats,
+			assembly:      assembly,
+			contents:      contents,
+			manifest:      manifest,
+			skipExisting:  skipExisting,
+			extendedNames: extendedNames,
+			retries:       retries,
+			backoff:       backoff,
+
+			holdingsURL:    rcsbHoldingsURL,
+			obsoletePolicy: obsoletePolicy,
+		}
+
+		if progressInterval > 0 {
+			progress := newByteProgress()
+			client.progress = progress.add
+			stop := progress.report(os.Stderr, progressInterval)
+			defer stop()
+		}
+
+		// Tokens are only deduplicated once the chain selections of all of
+		// them are known.
+		var tokens []string
+		if uniprot {
+			tokens, err = readUniProtPDBChainTokens(args, column, os.Stdin, siftsSource, outputPath, siftsMaxAge, minCoverage)
+		} else {
+			tokens, err = collectIdTokens(args, column, os.Stdin, "PDB IDs")
+		}
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		if extract {
+			if client.selections, err = chainSelections(tokens); err != nil {
+				logger.Fatalln(err)
+			}
+		}
+		ids, duplicates := dedupePDBIdTokens(tokens)
+		if duplicates > 0 {
+			logger.Printf("Removed %d duplicate PDB IDs", duplicates)
 		}
 
-		fetchPDB(args, outputPath, client)
+		if filter.active() {
+			client.rejected, err = filterEntries(client, rcsbGraphQLURL, ids, filter)
+			if err != nil {
+				logger.Fatalln(err)
+			}
+		}
+
+		results := fetchPDB(ids, outputPath, client, jobs)
+
+		reportPath, err := writeFetchReport(results, path.Join(outputPath, "fetchpdb_report"), reportFormat)
+		if err != nil {
+			logger.Println(err)
+		} else {
+			logger.Printf("Wrote report to %s", reportPath)
+		}
+
+		if err != nil || countFailed(results) > 0 {
+			manifest.Close()
+			logFile.Close()
+			os.Exit(1)
+		}
 	},
 }
 
@@ -164,4 +579,28 @@ func init() {
 	rootCmd.AddCommand(fetchpdbCmd)
 
 	fetchpdbCmd.Flags().StringP("output", "o", ".", "Output directory")
+	fetchpdbCmd.Flags().StringP("column", "c", "", "Read PDB IDs from this column (name or 1-based index) of CSV/TSV input files")
+	fetchpdbCmd.Flags().BoolP("uniprot", "u", false, "Read UniProt accessions and fetch the PDB entries mapped to them by SIFTS")
+	fetchpdbCmd.Flags().StringP("sifts", "", siftsURL, "SIFTS pdb_chain_uniprot.tsv file or URL used with --uniprot")
+	fetchpdbCmd.Flags().DurationP("sifts-max-age", "", siftsMaxAge, "Download the SIFTS file again once the copy in the output directory is this old")
+	fetchpdbCmd.Flags().IntP("min-coverage", "", 0, "With --uniprot, skip chains covering fewer UniProt residues")
+	fetchpdbCmd.Flags().Float64P("max-resolution", "", 0, "Skip entries with a worse resolution in Å, or none")
+	fetchpdbCmd.Flags().StringSliceP("method", "", nil, "Skip entries not solved by one of these experimental methods (xray, nmr, em, ...)")
+	fetchpdbCmd.Flags().StringP("min-release-date", "", "", "Skip entries released before this date (YYYY-MM-DD)")
+	fetchpdbCmd.Flags().IntP("jobs", "j", 4, "Number of parallel downloads")
+	fetchpdbCmd.Flags().StringP("format", "f", "pdb", "Structure file format (pdb, cif, bcif or xml)")
+	fetchpdbCmd.Flags().StringP("assembly", "a", "", "Download biological assembly N, or all assemblies, instead of the asymmetric unit")
+	fetchpdbCmd.Flags().StringSliceP("content", "", []string{contentCoords}, "Files to download per entry: coords, sf, mr, cs, nmr (mr and cs), validation, validation-xml")
+	fetchpdbCmd.Flags().BoolP("fallback", "", false, "Download mmCIF when no legacy PDB file is available")
+	fetchpdbCmd.Flags().StringP("obsolete", "", obsoleteSkip, "Handling of obsolete entries: skip, fetch-archive or follow (download the superseding entry)")
+	fetchpdbCmd.Flags().BoolP("extract", "", false, "Write the chains and residue ranges selected by suffixes such as 1abc_A or 2def_B:10-150 to files of their own")
+	fetchpdbCmd.Flags().StringSliceP("mirror", "m", nil, "Mirror preset (rcsb, pdbe, pdbj, wwpdb) or URL template; repeat to set the failover order")
+	fetchpdbCmd.Flags().StringP("mirror-config", "", defaultMirrorConfig(), "Mirror configuration file")
+	fetchpdbCmd.Flags().BoolP("extended-names", "", false, "Name output files by extended PDB ID (PDB_00001ABC.pdb)")
+	fetchpdbCmd.Flags().IntP("retries", "", 3, "Number of retries after a transient failure")
+	fetchpdbCmd.Flags().DurationP("backoff", "", time.Second, "Initial delay between retries, doubled on every attempt")
+	fetchpdbCmd.Flags().BoolP("skip-existing", "", false, "Skip entries already recorded in the manifest")
+	fetchpdbCmd.Flags().BoolP("verify", "", false, "Verify downloaded files against the manifest instead of fetching")
+	fetchpdbCmd.Flags().DurationP("progress", "", 0, "Print bytes received to stderr at this interval (e.g. 5s)")
+	fetchpdbCmd.Flags().StringP("report-format", "", "tsv", "Format of the per-ID fetch report (tsv or json)")
 }
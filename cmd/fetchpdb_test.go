@@ -13,44 +13,62 @@ import (
 	"testing"
 )
 
-func Test_validatePDBId(t *testing.T) {
+func Test_parsePDBIdToken(t *testing.T) {
 	testCases := []struct {
-		input    []string
-		expected []string
-		err      bool
+		input string
+		id    string
+		chain string
+		err   bool
 	}{
-		{
-			input:    []string{"1abc", "2DEF", "3GhI"},
-			expected: []string{"1abc", "2def", "3ghi"},
-			err:      false,
-		},
-		{
-			input:    []string{"1abc", "2DEF", "3Gh"},
-			expected: nil,
-			err:      true,
-		},
-		{
-			input:    []string{"1abc", "2DEF", "3GhJk"},
-			expected: nil,
-			err:      true,
-		},
-		{
-			input:    []string{"1abc", "2DEF", ""},
-			expected: nil,
-			err:      true,
-		},
+		{input: "1abc", id: "1abc"},
+		{input: "2DEF", id: "2def"},
+		{input: " 3GhI ", id: "3ghi"},
+		{input: "1abc_A", id: "1abc", chain: "A"},
+		{input: "3Gh", err: true},
+		{input: "3GhJk", err: true},
+		{input: "", err: true},
+		{input: "pdb_00001abc", id: "1abc"},
+		{input: "PDB_00002DEF", id: "2def"},
+		{input: "pdb_0001ab9z", id: "pdb_0001ab9z"},
+		{input: "pdb_0001abc", err: true},
+		{input: "xyz_00001abc", err: true},
+		{input: "pdb_0001ab-c", err: true},
 	}
 
 	for i, testCase := range testCases {
 		t.Run(fmt.Sprintf("Test case %d", i+1), func(t *testing.T) {
-			result, err := validatePDBId(testCase.input)
+			id, chain, err := parsePDBIdToken(testCase.input)
 
 			if (err != nil) != testCase.err {
 				t.Errorf("Expected error: %v, got: %v", testCase.err, err)
 			}
 
-			if !equalStringSlices(result, testCase.expected) {
-				t.Errorf("Expected result: %v, got: %v", testCase.expected, result)
+			if id != testCase.id || chain != testCase.chain {
+				t.Errorf("Expected %q chain %q, got: %q chain %q", testCase.id, testCase.chain, id, chain)
+			}
+		})
+	}
+}
+
+func Test_extendedPDBId(t *testing.T) {
+	testCases := []struct {
+		id       string
+		extended string
+		classic  string
+	}{
+		{id: "1abc", extended: "pdb_00001abc", classic: "1abc"},
+		{id: "pdb_00001abc", extended: "pdb_00001abc", classic: "1abc"},
+		{id: "pdb_0001ab9z", extended: "pdb_0001ab9z", classic: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			if extended := extendedPDBId(tc.id); extended != tc.extended {
+				t.Errorf("Expected extended ID %q, got %q", tc.extended, extended)
+			}
+			classic, ok := classicPDBId(tc.id)
+			if classic != tc.classic || ok != (tc.classic != "") {
+				t.Errorf("Expected classic ID %q, got %q (%v)", tc.classic, classic, ok)
 			}
 		})
 	}
@@ -68,7 +86,7 @@ func equalStringSlices(a, b []string) bool {
 	return true
 }
 
-func Test_readPDBIdList(t *testing.T) {
+func Test_collectIdTokens(t *testing.T) {
 
 	logger = log.New(ioutil.Discard, "", 0)
 
@@ -78,7 +96,7 @@ func Test_readPDBIdList(t *testing.T) {
 	}
 	defer os.Remove(tmpFile.Name())
 
-	content := []byte("1abc\n2def\n3ghi\n")
+	content := []byte("1abc\n2def\n3ghi\n1abc_B\n")
 	if _, err := tmpFile.Write(content); err != nil {
 		t.Fatalf("Failed to write to temporary file: %v", err)
 	}
@@ -92,17 +110,22 @@ func Test_readPDBIdList(t *testing.T) {
 		err      bool
 	}{
 		{
-			input:    []string{"1abc", "2DEF", "3GhI"},
-			expected: []string{"1abc", "2def", "3ghi"},
+			input:    []string{"1abc", "2DEF,3GhI"},
+			expected: []string{"1abc", "2DEF", "3GhI"},
 			err:      false,
 		},
 		{
-			input:    []string{tmpFile.Name()},
-			expected: []string{"1abc", "2def", "3ghi"},
+			input:    []string{tmpFile.Name(), "1abc_A"},
+			expected: []string{"1abc", "2def", "3ghi", "1abc_B", "1abc_A"},
 			err:      false,
 		},
 		{
 			input:    []string{"nonexistent.txt"},
+			expected: []string{"nonexistent.txt"},
+			err:      false,
+		},
+		{
+			input:    []string{t.TempDir()},
 			expected: nil,
 			err:      true,
 		},
@@ -110,7 +133,7 @@ func Test_readPDBIdList(t *testing.T) {
 
 	for i, testCase := range testCases {
 		t.Run(fmt.Sprintf("Test case %d", i+1), func(t *testing.T) {
-			result, err := readPDBIdList(testCase.input)
+			result, err := collectIdTokens(testCase.input, "", strings.NewReader(""), "PDB IDs")
 
 			if (err != nil) != testCase.err {
 				t.Errorf("Expected error: %v, got: %v", testCase.err, err)
@@ -123,6 +146,52 @@ func Test_readPDBIdList(t *testing.T) {
 	}
 }
 
+func Test_readPDBIdTokens(t *testing.T) {
+	logger = log.New(ioutil.Discard, "", 0)
+
+	listFile := path.Join(t.TempDir(), "ids.txt")
+	if err := ioutil.WriteFile(listFile, []byte("# list\n1abc 2def\n3ghi_A\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	csvFile := path.Join(t.TempDir(), "ids.csv")
+	if err := ioutil.WriteFile(csvFile, []byte("entry,method\n4jkl,X-RAY\n1ABC,NMR\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		input    []string
+		column   string
+		stdin    string
+		expected []string
+	}{
+		{
+			name:     "Files, literal IDs and stdin",
+			input:    []string{listFile, "5mno,6pqr", "-", "2DEF"},
+			stdin:    "7stu\n1abc\n",
+			expected: []string{"1abc", "2def", "3ghi_A", "5mno", "6pqr", "7stu"},
+		},
+		{
+			name:     "Column of a CSV file",
+			input:    []string{csvFile, "5mno"},
+			column:   "entry",
+			expected: []string{"4jkl", "1ABC", "5mno"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := readPDBIdTokens(tc.input, tc.column, strings.NewReader(tc.stdin))
+			if err != nil {
+				t.Fatalf("readPDBIdTokens returned error: %v", err)
+			}
+			if !equalStringSlices(result, tc.expected) {
+				t.Errorf("Expected result: %v, got: %v", tc.expected, result)
+			}
+		})
+	}
+}
+
 // func Test_PDBClient_fetch(t *testing.T) {
 // 	testPDBID := "1abc"
 // 	testPDBData := "dummy pdb data"
@@ -170,7 +239,7 @@ func Test_readPDBIdList(t *testing.T) {
 
 func Test_fetchPDB(t *testing.T) {
 	testPDBID := "1abc"
-	testPDBData := "dummy pdb data"
+	testPDBData := "HEADER    dummy pdb data"
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/octet-stream")
@@ -185,13 +254,13 @@ func Test_fetchPDB(t *testing.T) {
 	input := []string{testPDBID}
 
 	client := &PDBClient{
-		scheme: ts.URL,
-		client: &http.Client{},
+		mirrors: testMirrors(ts),
+		client:  &http.Client{},
 	}
 
 	logger = log.New(ioutil.Discard, "", 0)
 
-	fetchPDB(input, outputPath, client)
+	fetchPDB(input, outputPath, client, 1)
 
 	filename := path.Join(outputPath, strings.ToUpper(testPDBID)+".pdb")
 	content, err := ioutil.ReadFile(filename)
@@ -204,6 +273,156 @@ func Test_fetchPDB(t *testing.T) {
 	}
 }
 
+func Test_fetchPDB_jobs(t *testing.T) {
+	testPDBIDs := []string{"1abc", "2def", "3ghi", "4jkl", "5mno"}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write([]byte("HEADER    " + path.Base(r.URL.Path)))
+	}))
+	defer ts.Close()
+
+	outputPath := t.TempDir()
+
+	client := &PDBClient{
+		mirrors: testMirrors(ts),
+		client:  &http.Client{},
+	}
+
+	var logBuffer strings.Builder
+	logger = log.New(&logBuffer, "", 0)
+
+	fetchPDB(testPDBIDs, outputPath, client, 3)
+
+	for _, id := range testPDBIDs {
+		filename := path.Join(outputPath, strings.ToUpper(id)+".pdb")
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("could not read fetched file: %v", err)
+		}
+		if string(content) != "HEADER    "+id+".pdb.gz" {
+			t.Errorf("expected content: %s, got: %s", "HEADER    "+id+".pdb.gz", string(content))
+		}
+	}
+
+	lastIndex := -1
+	for _, id := range testPDBIDs {
+		index := strings.Index(logBuffer.String(), "Loaded "+id)
+		if index < lastIndex {
+			t.Errorf("log lines are out of order:\n%s", logBuffer.String())
+		}
+		lastIndex = index
+	}
+}
+
+func Test_fetchPDB_partialFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "2def") {
+			http.NotFound(w, r)
+			return
+		}
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write([]byte("HEADER    dummy pdb data"))
+	}))
+	defer ts.Close()
+
+	outputPath := t.TempDir()
+
+	client := &PDBClient{
+		mirrors: testMirrors(ts),
+		client:  &http.Client{},
+	}
+
+	logger = log.New(ioutil.Discard, "", 0)
+
+	results := fetchPDB([]string{"1abc", "2def", "bad", "3GHI"}, outputPath, client, 2)
+
+	expected := []struct {
+		id     string
+		status fetchStatus
+	}{
+		{"1abc", statusOK},
+		{"2def", statusNotFound},
+		{"bad", statusInvalid},
+		{"3ghi", statusOK},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, e := range expected {
+		if results[i].id != e.id || results[i].status != e.status {
+			t.Errorf("Result %d: expected (%s, %s), got (%s, %s)", i, e.id, e.status, results[i].id, results[i].status)
+		}
+	}
+
+	if _, err := os.Stat(path.Join(outputPath, "3GHI.pdb")); err != nil {
+		t.Errorf("Expected 3GHI.pdb to be fetched after earlier failures: %v", err)
+	}
+}
+
+func Test_fetchPDB_contents(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var content string
+		switch r.URL.Path {
+		case "/1abc.pdb.gz", "/2def.pdb.gz":
+			content = "HEADER    dummy pdb data"
+		case "/1abc-sf.cif.gz":
+			content = "data_r1abcsf\n"
+		case "/validation_reports/ab/1abc/1abc_validation.pdf.gz",
+			"/validation_reports/de/2def/2def_validation.pdf.gz":
+			content = "%PDF-1.5\n"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write([]byte(content))
+	}))
+	defer ts.Close()
+
+	outputPath := t.TempDir()
+
+	client := &PDBClient{
+		mirrors:  testMirrors(ts),
+		client:   &http.Client{},
+		contents: []string{contentCoords, "sf", "validation"},
+	}
+
+	logger = log.New(ioutil.Discard, "", 0)
+
+	results := fetchPDB([]string{"1abc", "2def"}, outputPath, client, 3)
+
+	expected := []struct {
+		id      string
+		content string
+		status  fetchStatus
+		file    string
+	}{
+		{"1abc", contentCoords, statusOK, "1ABC.pdb"},
+		{"1abc", "sf", statusOK, "1ABC-sf.cif"},
+		{"1abc", "validation", statusOK, "1ABC_validation.pdf"},
+		{"2def", contentCoords, statusOK, "2DEF.pdb"},
+		{"2def", "sf", statusNotFound, ""},
+		{"2def", "validation", statusOK, "2DEF_validation.pdf"},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, e := range expected {
+		r := results[i]
+		if r.id != e.id || r.content != e.content || r.status != e.status {
+			t.Errorf("Result %d: expected (%s, %s, %s), got (%s, %s, %s)", i, e.id, e.content, e.status, r.id, r.content, r.status)
+		}
+		if e.file != "" && !equalStringSlices(r.filenames, []string{path.Join(outputPath, e.file)}) {
+			t.Errorf("Result %d: expected file %s, got %v", i, e.file, r.filenames)
+		}
+	}
+}
+
 func Test_fetchPDB_API(t *testing.T) {
 	testPDBID := "3NIR"
 
@@ -212,15 +431,13 @@ func Test_fetchPDB_API(t *testing.T) {
 	input := []string{testPDBID}
 
 	client := &PDBClient{
-		scheme: "https",
-		host:   "files.rcsb.org",
-		path:   "download",
-		client: &http.Client{},
+		mirrors: defaultMirrors(),
+		client:  &http.Client{},
 	}
 
 	logger = log.New(ioutil.Discard, "", 0)
 
-	fetchPDB(input, outputPath, client)
+	fetchPDB(input, outputPath, client, 1)
 
 	filename := path.Join(outputPath, strings.ToUpper(testPDBID)+".pdb")
 	_, err := os.Stat(filename)
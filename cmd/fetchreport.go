@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+type fetchStatus string
+
+const (
+	statusOK            fetchStatus = "ok"
+	statusInvalid       fetchStatus = "invalid"
+	statusNotFound      fetchStatus = "not_found"
+	statusNetworkError  fetchStatus = "network_error"
+	statusCorrupt       fetchStatus = "corrupt"
+	statusHTTPError     fetchStatus = "http_error"
+	statusSkipped       fetchStatus = "skipped"
+	statusFiltered      fetchStatus = "filtered"
+	statusObsolete      fetchStatus = "obsolete"
+	statusExtractFailed fetchStatus = "extract_failed"
+
+	statusMissing          fetchStatus = "missing"
+	statusChecksumMismatch fetchStatus = "checksum_mismatch"
+	statusError            fetchStatus = "error"
+)
+
+type fetchResult struct {
+	index   int
+	id      string
+	content string
+	// filenames are the downloaded files, one per assembly if all
+	// assemblies are requested.
+	filenames []string
+	status    fetchStatus
+	err       error
+
+	// obsolete is set for obsolete entries, and replacedBy for those
+	// downloaded as the entry that superseded them.
+	obsolete   bool
+	replacedBy string
+
+	// extracted lists the files written for chain selections.
+	extracted []string
+}
+
+// fetchReportEntry is the serialized form of a fetchResult.
+type fetchReportEntry struct {
+	ID         string      `json:"id"`
+	Content    string      `json:"content,omitempty"`
+	Status     fetchStatus `json:"status"`
+	File       string      `json:"file,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	ReplacedBy string      `json:"replaced_by,omitempty"`
+}
+
+func classifyFetchError(err error) fetchStatus {
+	var netErr net.Error
+	var statusErr *httpStatusError
+	var corruptErr flate.CorruptInputError
+
+	switch {
+	case err == nil:
+		return statusOK
+	case errors.Is(err, errNotFound):
+		return statusNotFound
+	case errors.Is(err, errInvalidContent),
+		errors.Is(err, gzip.ErrHeader),
+		errors.Is(err, gzip.ErrChecksum),
+		errors.As(err, &corruptErr):
+		return statusCorrupt
+	case isTransient(err):
+		return statusNetworkError
+	case errors.As(err, &statusErr):
+		return statusHTTPError
+	case errors.As(err, &netErr):
+		return statusNetworkError
+	default:
+		return statusError
+	}
+}
+
+func countFailed(results []fetchResult) int {
+	failed := 0
+	for _, r := range results {
+		switch r.status {
+		case statusOK, statusSkipped, statusFiltered, statusObsolete:
+		default:
+			failed++
+		}
+	}
+	return failed
+}
+
+// summarizeFetchResults returns a one-line overview such as
+// "3 of 5 files (not_found: 1, skipped: 1)".
+func summarizeFetchResults(results []fetchResult) string {
+	counts := make(map[fetchStatus]int)
+	for _, r := range results {
+		counts[r.status]++
+	}
+
+	summary := fmt.Sprintf("%d of %d files", counts[statusOK], len(results))
+
+	var others []string
+	for status, n := range counts {
+		if status != statusOK {
+			others = append(others, fmt.Sprintf("%s: %d", status, n))
+		}
+	}
+	if len(others) > 0 {
+		sort.Strings(others)
+		summary += " (" + strings.Join(others, ", ") + ")"
+	}
+	return summary
+}
+
+func validateReportFormat(format string) error {
+	switch format {
+	case "tsv", "json":
+		return nil
+	default:
+		return fmt.Errorf("unknown report format: %s", format)
+	}
+}
+
+// writeFetchReport writes results to basename with an extension matching
+// format and returns the path of the written file.
+func writeFetchReport(results []fetchResult, basename, format string) (string, error) {
+	if err := validateReportFormat(format); err != nil {
+		return "", err
+	}
+
+	entries := make([]fetchReportEntry, len(results))
+	for i, r := range results {
+		entries[i] = fetchReportEntry{ID: r.id, Content: r.content, Status: r.status, File: strings.Join(r.filenames, ","), ReplacedBy: r.replacedBy}
+		if r.err != nil {
+			entries[i].Error = r.err.Error()
+		}
+	}
+
+	filename := basename + "." + format
+	file, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries); err != nil {
+			return "", err
+		}
+	case "tsv":
+		writer := csv.NewWriter(file)
+		writer.Comma = '\t'
+		if err := writer.Write([]string{"id", "content", "status", "file", "error", "replaced_by"}); err != nil {
+			return "", err
+		}
+		for _, e := range entries {
+			if err := writer.Write([]string{e.ID, e.Content, string(e.Status), e.File, e.Error, e.ReplacedBy}); err != nil {
+				return "", err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return "", err
+		}
+	}
+
+	return filename, file.Close()
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func Test_classifyFetchError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected fetchStatus
+	}{
+		{
+			name:     "No error",
+			err:      nil,
+			expected: statusOK,
+		},
+		{
+			name:     "Not found",
+			err:      fmt.Errorf("1abc: %w", errNotFound),
+			expected: statusNotFound,
+		},
+		{
+			name:     "Bad gzip header",
+			err:      gzip.ErrHeader,
+			expected: statusCorrupt,
+		},
+		{
+			name:     "Network error",
+			err:      &url.Error{Op: "Get", URL: "https://example.org", Err: &timeoutError{}},
+			expected: statusNetworkError,
+		},
+		{
+			name:     "Truncated transfer",
+			err:      fmt.Errorf("1abc: %w", io.ErrUnexpectedEOF),
+			expected: statusNetworkError,
+		},
+		{
+			name:     "Connection reset",
+			err:      &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET},
+			expected: statusNetworkError,
+		},
+		{
+			name:     "Gone",
+			err:      &httpStatusError{url: "https://example.org", statusCode: 410},
+			expected: statusNotFound,
+		},
+		{
+			name:     "Server error",
+			err:      &httpStatusError{url: "https://example.org", statusCode: 503},
+			expected: statusNetworkError,
+		},
+		{
+			name:     "Forbidden",
+			err:      &httpStatusError{url: "https://example.org", statusCode: 403},
+			expected: statusHTTPError,
+		},
+		{
+			name:     "Invalid content",
+			err:      errInvalidContent,
+			expected: statusCorrupt,
+		},
+		{
+			name:     "Other error",
+			err:      errors.New("disk full"),
+			expected: statusError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status := classifyFetchError(tc.err)
+			if status != tc.expected {
+				t.Errorf("Expected status %s, got %s", tc.expected, status)
+			}
+		})
+	}
+}
+
+type timeoutError struct{}
+
+func (e *timeoutError) Error() string   { return "i/o timeout" }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+func Test_summarizeFetchResults(t *testing.T) {
+	results := []fetchResult{
+		{id: "1abc", status: statusOK},
+		{id: "2def", status: statusNotFound},
+		{id: "3ghi", status: statusOK},
+		{id: "xx", status: statusInvalid},
+	}
+
+	expected := "2 of 4 files (invalid: 1, not_found: 1)"
+	if summary := summarizeFetchResults(results); summary != expected {
+		t.Errorf("Expected summary %q, got %q", expected, summary)
+	}
+	if failed := countFailed(results); failed != 2 {
+		t.Errorf("Expected 2 failed, got %d", failed)
+	}
+}
+
+func Test_writeFetchReport(t *testing.T) {
+	results := []fetchResult{
+		{id: "1abc", content: contentCoords, status: statusOK, filenames: []string{"out/1ABC.pdb"}},
+		{id: "2def", content: "sf", status: statusNotFound, err: errNotFound},
+	}
+	basename := path.Join(t.TempDir(), "report")
+
+	t.Run("TSV", func(t *testing.T) {
+		filename, err := writeFetchReport(results, basename, "tsv")
+		if err != nil {
+			t.Fatalf("writeFetchReport returned error: %v", err)
+		}
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "id\tcontent\tstatus\tfile\terror\treplaced_by\n1abc\tcoords\tok\tout/1ABC.pdb\t\t\n2def\tsf\tnot_found\t\tentry not found\t\n"
+		if string(content) != expected {
+			t.Errorf("Expected:\n%s\nActual:\n%s", expected, string(content))
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		filename, err := writeFetchReport(results, basename, "json")
+		if err != nil {
+			t.Fatalf("writeFetchReport returned error: %v", err)
+		}
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries []fetchReportEntry
+		if err := json.Unmarshal(content, &entries); err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 || entries[1].Status != statusNotFound || entries[1].Error != "entry not found" {
+			t.Errorf("Unexpected report entries: %+v", entries)
+		}
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		if _, err := writeFetchReport(results, basename, "xml"); err == nil || !strings.Contains(err.Error(), "xml") {
+			t.Errorf("Expected unknown format error, got %v", err)
+		}
+	})
+}
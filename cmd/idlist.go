@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// splitChainSuffix separates an optional chain selection such as _A or
+// _B:10-150 from a PDB ID token. The underscore in the pdb_ prefix of
+// extended IDs is not treated as a separator.
+func splitChainSuffix(token string) (id, chain string) {
+	offset := 0
+	if len(token) > len(extendedPDBIdPrefix) && strings.EqualFold(token[:len(extendedPDBIdPrefix)], extendedPDBIdPrefix) {
+		offset = len(extendedPDBIdPrefix)
+	}
+	if i := strings.IndexByte(token[offset:], '_'); i != -1 {
+		return token[:offset+i], token[offset+i+1:]
+	}
+	return token, ""
+}
+
+// parsePDBIdToken validates a PDB ID token that may carry a chain suffix and
+// returns the normalized ID together with the chain selection.
+func parsePDBIdToken(token string) (id, chain string, err error) {
+	id, chain = splitChainSuffix(strings.TrimSpace(token))
+	id, err = normalizePDBId(id)
+	if err != nil {
+		return "", "", err
+	}
+	return id, chain, nil
+}
+
+// normalizePDBIdToken is parsePDBIdToken without the chain selection.
+func normalizePDBIdToken(token string) (string, error) {
+	id, _, err := parsePDBIdToken(token)
+	return id, err
+}
+
+func isIdSeparator(c rune) bool {
+	return c == ',' || c == ';' || unicode.IsSpace(c)
+}
+
+// splitIdTokens splits free text into PDB ID tokens separated by whitespace,
+// commas or semicolons. Everything after a # is a comment.
+func splitIdTokens(text string) []string {
+	if i := strings.IndexByte(text, '#'); i != -1 {
+		text = text[:i]
+	}
+	return strings.FieldsFunc(text, isIdSeparator)
+}
+
+// parseIdList reads PDB ID tokens from r. Without a column, r is treated as
+// free text. With a column, r is read as a CSV or TSV table with a header
+// row, and tokens are taken from the column with that name or 1-based index.
+func parseIdList(r io.Reader, column string) ([]string, error) {
+	if column != "" {
+		return parseIdTable(r, column)
+	}
+
+	var tokens []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		tokens = append(tokens, splitIdTokens(scanner.Text())...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func parseIdTable(r io.Reader, column string) ([]string, error) {
+	buffered := bufio.NewReader(r)
+	headerLine, err := buffered.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	delimiter := ','
+	if strings.ContainsRune(headerLine, '\t') {
+		delimiter = '\t'
+	}
+
+	reader := csv.NewReader(io.MultiReader(strings.NewReader(headerLine), buffered))
+	reader.Comma = delimiter
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	columnIndex, err := indexOf(header, column)
+	if err != nil {
+		number, convErr := strconv.Atoi(column)
+		if convErr != nil || number < 1 || number > len(header) {
+			return nil, err
+		}
+		columnIndex = number - 1
+	}
+
+	var tokens []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if columnIndex >= len(record) {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d has no column %s", line, column)
+		}
+		if value := strings.TrimSpace(record[columnIndex]); value != "" {
+			tokens = append(tokens, value)
+		}
+	}
+	return tokens, nil
+}
+
+// dedupePDBIdTokens removes tokens that refer to an entry seen earlier,
+// keeping the first occurrence. Tokens that are not valid IDs are compared
+// verbatim so that they are still reported.
+func dedupePDBIdTokens(tokens []string) ([]string, int) {
+	return dedupeIdTokens(tokens, normalizePDBIdToken)
+}
+
+// dedupeIdTokens is dedupePDBIdTokens for IDs validated by normalize.
+func dedupeIdTokens(tokens []string, normalize func(string) (string, error)) ([]string, int) {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, token := range tokens {
+		key := token
+		if id, err := normalize(token); err == nil {
+			key = id
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, token)
+	}
+	return unique, len(tokens) - len(unique)
+}
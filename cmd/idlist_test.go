@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_splitChainSuffix(t *testing.T) {
+	testCases := []struct {
+		token string
+		id    string
+		chain string
+	}{
+		{token: "1abc", id: "1abc", chain: ""},
+		{token: "1abc_A", id: "1abc", chain: "A"},
+		{token: "2def_B:10-150", id: "2def", chain: "B:10-150"},
+		{token: "pdb_00001abc", id: "pdb_00001abc", chain: ""},
+		{token: "PDB_00001ABC_C", id: "PDB_00001ABC", chain: "C"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.token, func(t *testing.T) {
+			id, chain := splitChainSuffix(tc.token)
+			if id != tc.id || chain != tc.chain {
+				t.Errorf("Expected (%q, %q), got (%q, %q)", tc.id, tc.chain, id, chain)
+			}
+		})
+	}
+}
+
+func Test_parseIdList(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		column   string
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "One per line",
+			input:    "1abc\n2def\n",
+			expected: []string{"1abc", "2def"},
+		},
+		{
+			name:     "Comments, blank lines and trailing whitespace",
+			input:    "# my favourite structures\n1abc   \n\n2def # kinase\n\t3ghi\r\n",
+			expected: []string{"1abc", "2def", "3ghi"},
+		},
+		{
+			name:     "Separated by commas and spaces",
+			input:    "1abc, 2def 3ghi;4jkl\n",
+			expected: []string{"1abc", "2def", "3ghi", "4jkl"},
+		},
+		{
+			name:     "CSV column by name",
+			input:    "Entry ID,Resolution\n1ABC,2.1\n2DEF,1.8\n",
+			column:   "Entry ID",
+			expected: []string{"1ABC", "2DEF"},
+		},
+		{
+			name:     "TSV column by index",
+			input:    "score\tpdb\tchain\n0.9\t1abc\tA\n0.8\t\tB\n0.7\t2def\tC\n",
+			column:   "2",
+			expected: []string{"1abc", "2def"},
+		},
+		{
+			name:   "Unknown column",
+			input:  "Entry ID,Resolution\n1ABC,2.1\n",
+			column: "pdb",
+			err:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := parseIdList(strings.NewReader(tc.input), tc.column)
+			if (err != nil) != tc.err {
+				t.Errorf("Expected error: %v, got: %v", tc.err, err)
+			}
+			if !equalStringSlices(result, tc.expected) {
+				t.Errorf("Expected result: %v, got: %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func Test_dedupePDBIdTokens(t *testing.T) {
+	tokens := []string{"1abc", "1ABC_A", "pdb_00001abc", "2def", "bad", "bad"}
+
+	unique, duplicates := dedupePDBIdTokens(tokens)
+
+	expected := []string{"1abc", "2def", "bad"}
+	if !equalStringSlices(unique, expected) {
+		t.Errorf("Expected %v, got %v", expected, unique)
+	}
+	if duplicates != 3 {
+		t.Errorf("Expected 3 duplicates, got %d", duplicates)
+	}
+}
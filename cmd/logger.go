@@ -10,13 +10,19 @@ import (
 var logger *log.Logger
 
 func getLogger(filename string) (*log.Logger, *os.File, error) {
+	return getLoggerTo(filename, os.Stdout)
+}
+
+// getLoggerTo is getLogger for commands that write their results to standard
+// output. Their log goes to console, usually standard error, instead.
+func getLoggerTo(filename string, console io.Writer) (*log.Logger, *os.File, error) {
 	logFilename := filename + ".log"
 	logFile, err := os.Create(logFilename)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
+	multiWriter := io.MultiWriter(console, logFile)
 	loggerInstance := log.New(multiWriter, "INFO: ", log.Ldate|log.Ltime)
 	loggerInstance.Printf("kirill - Version: %s, Git Commit: %s, Logging to file %s", GitTag, GitCommit[:7], logFilename)
 
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const manifestFilename = "fetchpdb_manifest.tsv"
+
+var manifestHeader = []string{"id", "format", "file", "size", "sha256", "fetched_at"}
+
+type manifestEntry struct {
+	id        string
+	format    string
+	file      string
+	size      int64
+	sha256    string
+	fetchedAt time.Time
+}
+
+func (e manifestEntry) record() []string {
+	return []string{
+		e.id,
+		e.format,
+		e.file,
+		strconv.FormatInt(e.size, 10),
+		e.sha256,
+		e.fetchedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func parseManifestRecord(record []string) (manifestEntry, error) {
+	if len(record) != len(manifestHeader) {
+		return manifestEntry{}, fmt.Errorf("expected %d manifest columns, got %d", len(manifestHeader), len(record))
+	}
+	size, err := strconv.ParseInt(record[3], 10, 64)
+	if err != nil {
+		return manifestEntry{}, err
+	}
+	fetchedAt, err := time.Parse(time.RFC3339, record[5])
+	if err != nil {
+		return manifestEntry{}, err
+	}
+	return manifestEntry{
+		id:        record[0],
+		format:    record[1],
+		file:      record[2],
+		size:      size,
+		sha256:    record[4],
+		fetchedAt: fetchedAt,
+	}, nil
+}
+
+// fetchManifest keeps track of every file fetchpdb has written to an output
+// directory. Entries are appended as soon as a download completes, so the
+// manifest stays usable if a run is interrupted. When a file is downloaded
+// again the later entry wins.
+type fetchManifest struct {
+	mu      sync.Mutex
+	dir     string
+	entries map[string]manifestEntry
+	file    *os.File
+	writer  *csv.Writer
+}
+
+func openFetchManifest(dir string) (*fetchManifest, error) {
+	m := &fetchManifest{dir: dir, entries: make(map[string]manifestEntry)}
+	filename := path.Join(dir, manifestFilename)
+
+	if err := m.load(filename); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, statErr := os.Stat(filename)
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	m.file = file
+	m.writer = csv.NewWriter(file)
+	m.writer.Comma = '\t'
+
+	if os.IsNotExist(statErr) {
+		if err := m.writeRecord(manifestHeader); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *fetchManifest) load(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = '\t'
+	reader.FieldsPerRecord = -1
+
+	if _, err := reader.Read(); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		entry, err := parseManifestRecord(record)
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", filename, line, err)
+		}
+		m.entries[entry.file] = entry
+	}
+}
+
+func (m *fetchManifest) writeRecord(record []string) error {
+	if err := m.writer.Write(record); err != nil {
+		return err
+	}
+	m.writer.Flush()
+	return m.writer.Error()
+}
+
+func (m *fetchManifest) add(entry manifestEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[entry.file] = entry
+	return m.writeRecord(entry.record())
+}
+
+func (m *fetchManifest) lookup(file string) (manifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[file]
+	return entry, ok
+}
+
+// sortedEntries returns all entries ordered by file name.
+func (m *fetchManifest) sortedEntries() []manifestEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make([]manifestEntry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].file < entries[j].file })
+	return entries
+}
+
+func (m *fetchManifest) Close() error {
+	return m.file.Close()
+}
+
+func sha256File(filename string) (string, int64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// verifyManifest checks that every file recorded in the manifest is still
+// present and matches its recorded size and checksum.
+func verifyManifest(m *fetchManifest) []fetchResult {
+	entries := m.sortedEntries()
+	results := make([]fetchResult, len(entries))
+
+	for i, entry := range entries {
+		filename := path.Join(m.dir, entry.file)
+		results[i] = fetchResult{index: i, id: entry.id, filenames: []string{filename}, status: statusOK}
+
+		checksum, size, err := sha256File(filename)
+		switch {
+		case os.IsNotExist(err):
+			results[i].status = statusMissing
+			results[i].err = fmt.Errorf("file is missing")
+		case err != nil:
+			results[i].status = statusError
+			results[i].err = err
+		case size != entry.size || checksum != entry.sha256:
+			results[i].status = statusChecksumMismatch
+			results[i].err = fmt.Errorf("expected %d bytes with SHA-256 %s, got %d bytes with SHA-256 %s",
+				entry.size, entry.sha256, size, checksum)
+		}
+	}
+	return results
+}
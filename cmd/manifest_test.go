@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_fetchManifest_reopen(t *testing.T) {
+	dir := t.TempDir()
+
+	manifest, err := openFetchManifest(dir)
+	if err != nil {
+		t.Fatalf("openFetchManifest returned error: %v", err)
+	}
+	fetchedAt := time.Date(2023, 5, 5, 12, 0, 0, 0, time.UTC)
+	entries := []manifestEntry{
+		{id: "1abc", format: "pdb", file: "1ABC.pdb", size: 10, sha256: "aa", fetchedAt: fetchedAt},
+		{id: "2def", format: "cif", file: "2DEF.cif", size: 20, sha256: "bb", fetchedAt: fetchedAt},
+		{id: "1abc", format: "pdb", file: "1ABC.pdb", size: 11, sha256: "cc", fetchedAt: fetchedAt},
+	}
+	for _, entry := range entries {
+		if err := manifest.add(entry); err != nil {
+			t.Fatalf("add returned error: %v", err)
+		}
+	}
+	manifest.Close()
+
+	manifest, err = openFetchManifest(dir)
+	if err != nil {
+		t.Fatalf("openFetchManifest returned error on reopen: %v", err)
+	}
+	defer manifest.Close()
+
+	entry, ok := manifest.lookup("1ABC.pdb")
+	if !ok {
+		t.Fatal("1ABC.pdb not found in reopened manifest")
+	}
+	if entry.size != 11 || entry.sha256 != "cc" || !entry.fetchedAt.Equal(fetchedAt) {
+		t.Errorf("Expected the latest entry to win, got %+v", entry)
+	}
+	if len(manifest.sortedEntries()) != 2 {
+		t.Errorf("Expected 2 entries, got %d", len(manifest.sortedEntries()))
+	}
+}
+
+func Test_verifyManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	manifest, err := openFetchManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manifest.Close()
+
+	for _, name := range []string{"1ABC.pdb", "2DEF.pdb"} {
+		checksum, size, err := writeFileAtomic(path.Join(dir, name), strings.NewReader("HEADER    "+name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest.add(manifestEntry{id: name[:4], format: "pdb", file: name, size: size, sha256: checksum})
+	}
+	manifest.add(manifestEntry{id: "3GHI", format: "pdb", file: "3GHI.pdb", size: 1, sha256: "00"})
+	if err := ioutil.WriteFile(path.Join(dir, "2DEF.pdb"), []byte("HEADER    tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := verifyManifest(manifest)
+
+	expected := []fetchStatus{statusOK, statusChecksumMismatch, statusMissing}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, status := range expected {
+		if results[i].status != status {
+			t.Errorf("Result %d (%s): expected %s, got %s", i, results[i].id, status, results[i].status)
+		}
+	}
+}
+
+func Test_fetchPDB_skipExisting(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write([]byte("HEADER    dummy pdb data"))
+	}))
+	defer ts.Close()
+
+	outputPath := t.TempDir()
+	manifest, err := openFetchManifest(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manifest.Close()
+
+	client := &PDBClient{
+		mirrors:  testMirrors(ts),
+		client:   &http.Client{},
+		manifest: manifest,
+	}
+
+	logger = log.New(ioutil.Discard, "", 0)
+
+	fetchPDB([]string{"1abc", "2def"}, outputPath, client, 2)
+	if calls != 2 {
+		t.Fatalf("Expected 2 requests on first run, got %d", calls)
+	}
+
+	// A file that was removed since the last run is fetched again.
+	os.Remove(path.Join(outputPath, "2DEF.pdb"))
+	client.skipExisting = true
+	results := fetchPDB([]string{"1abc", "2def"}, outputPath, client, 2)
+
+	if calls != 3 {
+		t.Errorf("Expected 3 requests in total, got %d", calls)
+	}
+	if results[0].status != statusSkipped || results[1].status != statusOK {
+		t.Errorf("Expected (skipped, ok), got (%s, %s)", results[0].status, results[1].status)
+	}
+
+	files, err := ioutil.ReadDir(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if path.Ext(file.Name()) == ".tmp" {
+			t.Errorf("Temporary file %s left behind", file.Name())
+		}
+	}
+}
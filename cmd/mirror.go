@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// pdbMirror is a named download site. Its template is a URL in which the
+// following placeholders are replaced for every entry:
+//
+//	{id}           lower case PDB ID, e.g. 1abc
+//	{ID}           upper case PDB ID, e.g. 1ABC
+//	{hash}         middle two characters of the ID, e.g. ab
+//	{ext}          RCSB file extension, e.g. .cif.gz
+//	{archive_path} path in the wwPDB archive below pub/pdb/, e.g.
+//	               data/structures/divided/mmCIF/ab/1abc.cif.gz
+//
+// Files without an RCSB extension, such as validation reports, are fetched
+// from archive instead when template uses {ext}.
+type pdbMirror struct {
+	name     string
+	template string
+	archive  string
+}
+
+var mirrorPresets = []pdbMirror{
+	{"rcsb", "https://files.rcsb.org/download/{id}{ext}", "https://files.rcsb.org/pub/pdb/{archive_path}"},
+	{"pdbe", "https://ftp.ebi.ac.uk/pub/databases/pdb/{archive_path}", ""},
+	{"pdbj", "https://ftp.pdbj.org/pub/pdb/{archive_path}", ""},
+	{"wwpdb", "https://files.wwpdb.org/pub/pdb/{archive_path}", ""},
+}
+
+var errFormatUnsupported = errors.New("format not available")
+
+func defaultMirrors() []pdbMirror {
+	return mirrorPresets[:1]
+}
+
+func (m pdbMirror) url(id string, format structureFormat) (string, error) {
+	template := m.template
+	if format.remoteExtension == "" && strings.Contains(template, "{ext}") {
+		template = m.archive
+	}
+	if template == "" || (strings.Contains(template, "{archive_path}") && format.archivePath == "") {
+		return "", fmt.Errorf("%s: %s %w", m.name, format.name, errFormatUnsupported)
+	}
+
+	// The archive path contains placeholders itself, so it is expanded first.
+	template = strings.ReplaceAll(template, "{archive_path}", format.archivePath)
+	replacer := strings.NewReplacer(
+		"{id}", id,
+		"{ID}", strings.ToUpper(id),
+		"{hash}", pdbIdHash(id),
+		"{ext}", format.remoteExtension,
+	)
+	return replacer.Replace(template), nil
+}
+
+// pdbIdHash returns the two characters used to divide the wwPDB archive
+// into subdirectories.
+func pdbIdHash(id string) string {
+	if len(id) < 3 {
+		return id
+	}
+	return id[len(id)-3 : len(id)-1]
+}
+
+func validateMirrorTemplate(template string) error {
+	if !strings.Contains(template, "{id}") && !strings.Contains(template, "{ID}") &&
+		!strings.Contains(template, "{archive_path}") {
+		return fmt.Errorf("mirror template %q does not contain {id}, {ID} or {archive_path}", template)
+	}
+	if !strings.HasPrefix(template, "http://") && !strings.HasPrefix(template, "https://") &&
+		!strings.HasPrefix(template, "file://") {
+		return fmt.Errorf("mirror template %q must start with http://, https:// or file://", template)
+	}
+	return nil
+}
+
+// parseMirror resolves a preset name, a name defined in the config file or a
+// URL template into a mirror.
+func parseMirror(value string, named []pdbMirror) (pdbMirror, error) {
+	for _, m := range named {
+		if m.name == value {
+			return m, nil
+		}
+	}
+	for _, m := range mirrorPresets {
+		if m.name == strings.ToLower(value) {
+			return m, nil
+		}
+	}
+	if err := validateMirrorTemplate(value); err != nil {
+		return pdbMirror{}, fmt.Errorf("unknown mirror %q: %w", value, err)
+	}
+	return pdbMirror{name: value, template: value}, nil
+}
+
+// readMirrorConfig reads a mirror configuration file. Every non-empty line
+// that is not a # comment either names a mirror preset or defines a custom
+// mirror as "name template". Mirrors are returned in the order they appear,
+// which is the default failover order.
+func readMirrorConfig(filename string) ([]pdbMirror, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var mirrors []pdbMirror
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		var mirror pdbMirror
+		switch len(fields) {
+		case 1:
+			mirror, err = parseMirror(fields[0], nil)
+		case 2:
+			mirror = pdbMirror{name: fields[0], template: fields[1]}
+			err = validateMirrorTemplate(fields[1])
+		default:
+			err = fmt.Errorf("expected \"name template\", got %q", text)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", filename, line, err)
+		}
+		mirrors = append(mirrors, mirror)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mirrors, nil
+}
+
+// defaultMirrorConfig returns the path of the user's mirror configuration
+// file, which may not exist.
+func defaultMirrorConfig() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(dir, "kirill", "mirrors")
+}
+
+// resolveMirrors picks the mirrors to use in failover order: those given with
+// --mirror, otherwise those listed in the config file, otherwise RCSB.
+func resolveMirrors(values []string, configFilename string, configRequired bool) ([]pdbMirror, error) {
+	var configured []pdbMirror
+	if configFilename != "" {
+		var err error
+		configured, err = readMirrorConfig(configFilename)
+		if err != nil && (configRequired || !os.IsNotExist(err)) {
+			return nil, err
+		}
+	}
+
+	if len(values) == 0 {
+		if len(configured) > 0 {
+			return configured, nil
+		}
+		return defaultMirrors(), nil
+	}
+
+	mirrors := make([]pdbMirror, 0, len(values))
+	for _, value := range values {
+		mirror, err := parseMirror(value, configured)
+		if err != nil {
+			return nil, err
+		}
+		mirrors = append(mirrors, mirror)
+	}
+	return mirrors, nil
+}
+
+func mirrorNames(mirrors []pdbMirror) string {
+	names := make([]string, len(mirrors))
+	for i, m := range mirrors {
+		names[i] = m.name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// testMirrors returns a single mirror serving every file from ts.
+func testMirrors(ts *httptest.Server) []pdbMirror {
+	return []pdbMirror{{name: "test", template: ts.URL + "/{id}{ext}", archive: ts.URL + "/{archive_path}"}}
+}
+
+func Test_pdbMirror_url(t *testing.T) {
+	testCases := []struct {
+		mirror   string
+		format   structureFormat
+		expected string
+		err      bool
+	}{
+		{
+			mirror:   "rcsb",
+			format:   formatCIF,
+			expected: "https://files.rcsb.org/download/1abc.cif.gz",
+		},
+		{
+			mirror:   "pdbe",
+			format:   formatPDB,
+			expected: "https://ftp.ebi.ac.uk/pub/databases/pdb/data/structures/divided/pdb/ab/pdb1abc.ent.gz",
+		},
+		{
+			mirror:   "pdbj",
+			format:   formatCIF,
+			expected: "https://ftp.pdbj.org/pub/pdb/data/structures/divided/mmCIF/ab/1abc.cif.gz",
+		},
+		{
+			mirror:   "wwpdb",
+			format:   formatXML,
+			expected: "https://files.wwpdb.org/pub/pdb/data/structures/divided/XML/ab/1abc.xml.gz",
+		},
+		{
+			mirror: "wwpdb",
+			format: formatBCIF,
+			err:    true,
+		},
+		{
+			mirror:   "rcsb",
+			format:   mustForAssembly(formatPDB, 2),
+			expected: "https://files.rcsb.org/download/1abc.pdb2.gz",
+		},
+		{
+			mirror:   "rcsb",
+			format:   mustForAssembly(formatCIF, 1),
+			expected: "https://files.rcsb.org/download/1abc-assembly1.cif.gz",
+		},
+		{
+			mirror:   "pdbe",
+			format:   mustForAssembly(formatPDB, 1),
+			expected: "https://ftp.ebi.ac.uk/pub/databases/pdb/data/biounit/PDB/divided/ab/1abc.pdb1.gz",
+		},
+		{
+			mirror:   "wwpdb",
+			format:   mustForAssembly(formatCIF, 3),
+			expected: "https://files.wwpdb.org/pub/pdb/data/assemblies/mmCIF/divided/ab/1abc-assembly3.cif.gz",
+		},
+		{
+			mirror:   "rcsb",
+			format:   formatSF,
+			expected: "https://files.rcsb.org/download/1abc-sf.cif.gz",
+		},
+		{
+			mirror:   "rcsb",
+			format:   formatValidation,
+			expected: "https://files.rcsb.org/pub/pdb/validation_reports/ab/1abc/1abc_validation.pdf.gz",
+		},
+		{
+			mirror:   "pdbj",
+			format:   formatCS,
+			expected: "https://ftp.pdbj.org/pub/pdb/data/structures/divided/nmr_chemical_shifts/ab/1abc_cs.str.gz",
+		},
+		{
+			mirror:   "pdbe",
+			format:   formatSF,
+			expected: "https://ftp.ebi.ac.uk/pub/databases/pdb/data/structures/divided/structure_factors/ab/r1abcsf.ent.gz",
+		},
+		{
+			mirror: "https://pdb.example.org/{id}{ext}",
+			format: formatValidationXML,
+			err:    true,
+		},
+		{
+			mirror:   "https://pdb.example.org/{hash}/{ID}{ext}",
+			format:   formatPDB,
+			expected: "https://pdb.example.org/ab/1ABC.pdb.gz",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mirror+" "+tc.format.name, func(t *testing.T) {
+			mirror, err := parseMirror(tc.mirror, nil)
+			if err != nil {
+				t.Fatalf("parseMirror returned error: %v", err)
+			}
+			url, err := mirror.url("1abc", tc.format)
+			if (err != nil) != tc.err {
+				t.Errorf("Expected error: %v, got: %v", tc.err, err)
+			}
+			if url != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, url)
+			}
+		})
+	}
+}
+
+func mustForAssembly(format structureFormat, n int) structureFormat {
+	assembly, err := format.forAssembly(n)
+	if err != nil {
+		panic(err)
+	}
+	return assembly
+}
+
+func Test_parseMirror_invalid(t *testing.T) {
+	for _, value := range []string{"ebi", "https://pdb.example.org/files", "ftp://pdb.example.org/{id}"} {
+		if _, err := parseMirror(value, nil); err == nil {
+			t.Errorf("Expected error for %q, got nil", value)
+		}
+	}
+}
+
+func Test_resolveMirrors(t *testing.T) {
+	config := path.Join(t.TempDir(), "mirrors")
+	content := `# institutional mirror first
+local https://pdb.example.org/{id}{ext}
+pdbe
+`
+	if err := ioutil.WriteFile(config, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		values   []string
+		config   string
+		expected string
+	}{
+		{
+			name:     "Default",
+			expected: "rcsb",
+		},
+		{
+			name:     "Missing default config",
+			config:   path.Join(t.TempDir(), "missing"),
+			expected: "rcsb",
+		},
+		{
+			name:     "Config file",
+			config:   config,
+			expected: "local, pdbe",
+		},
+		{
+			name:     "Flags override config",
+			values:   []string{"pdbj", "local", "rcsb"},
+			config:   config,
+			expected: "pdbj, local, rcsb",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mirrors, err := resolveMirrors(tc.values, tc.config, false)
+			if err != nil {
+				t.Fatalf("resolveMirrors returned error: %v", err)
+			}
+			if names := mirrorNames(mirrors); names != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, names)
+			}
+		})
+	}
+
+	if _, err := resolveMirrors(nil, path.Join(t.TempDir(), "missing"), true); err == nil {
+		t.Error("Expected error for missing explicit config file, got nil")
+	}
+}
+
+func Test_PDBClient_fetch_failover(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	stale := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer stale.Close()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write([]byte("HEADER    dummy pdb data"))
+	}))
+	defer up.Close()
+
+	mirrors := append(append(testMirrors(down), testMirrors(stale)...), testMirrors(up)...)
+	client := &PDBClient{mirrors: mirrors, client: &http.Client{}}
+
+	if _, err := client.fetch("1abc", t.TempDir()); err != nil {
+		t.Errorf("Expected failover to succeed, got: %v", err)
+	}
+
+	client.mirrors = mirrors[:2]
+	_, err := client.fetch("1abc", t.TempDir())
+	if err == nil || errors.Is(err, errNotFound) || !isTransient(err) {
+		t.Errorf("Expected the outage to be reported, got: %v", err)
+	}
+
+	client.mirrors = mirrors[1:2]
+	if _, err := client.fetch("1abc", t.TempDir()); !errors.Is(err, errNotFound) {
+		t.Errorf("Expected not found, got: %v", err)
+	}
+}
+
+func Test_PDBClient_fetch_localMirror(t *testing.T) {
+	archive := t.TempDir()
+	if err := os.MkdirAll(path.Join(archive, "data", "structures", "divided", "pdb", "ab"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(path.Join(archive, "data", "structures", "divided", "pdb", "ab", "pdb1abc.ent.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	gz.Write([]byte("HEADER    local copy"))
+	gz.Close()
+	file.Close()
+
+	mirror, err := parseMirror("file://"+archive+"/{archive_path}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &PDBClient{mirrors: []pdbMirror{mirror}, client: newHTTPClient()}
+
+	filenames, err := client.fetch("1abc", t.TempDir())
+	if err != nil {
+		t.Fatalf("fetch() returned error: %v", err)
+	}
+	filename := filenames[0]
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "local copy") {
+		t.Errorf("Unexpected content: %s", content)
+	}
+
+	if _, err := client.fetch("2def", t.TempDir()); !errors.Is(err, errNotFound) {
+		t.Errorf("Expected not found for missing local file, got: %v", err)
+	}
+}
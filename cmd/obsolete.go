@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rcsbHoldingsURL is the RCSB holdings status endpoint; {ID} is replaced by
+// the upper case PDB ID.
+const rcsbHoldingsURL = "https://data.rcsb.org/rest/v1/holdings/status/{ID}"
+
+// What to do with obsolete entries, selected with --obsolete.
+const (
+	obsoleteSkip         = "skip"
+	obsoleteFetchArchive = "fetch-archive"
+	obsoleteFollow       = "follow"
+)
+
+func parseObsoletePolicy(value string) (string, error) {
+	switch value {
+	case obsoleteSkip, obsoleteFetchArchive, obsoleteFollow:
+		return value, nil
+	default:
+		return "", fmt.Errorf("unknown obsolete entry handling: %s (expected skip, fetch-archive or follow)", value)
+	}
+}
+
+// holdingsStatus is the part of a holdings status record that tells whether
+// an entry was obsoleted and by which entry.
+type holdingsStatus struct {
+	Combined struct {
+		Status     string `json:"status"`
+		StatusCode string `json:"status_code"`
+		ReplacedBy string `json:"id_code_replaced_by_latest"`
+	} `json:"rcsb_repository_holdings_combined"`
+}
+
+func (s holdingsStatus) obsolete() bool {
+	return s.Combined.StatusCode == "OBS" || s.Combined.Status == "REMOVED"
+}
+
+// holdingsLookup is the holdings status of an entry, looked up once however
+// many of its contents ask for it.
+type holdingsLookup struct {
+	once   sync.Once
+	status holdingsStatus
+	err    error
+}
+
+// cachedEntryStatus is entryStatus, asking the server only the first time an
+// entry is looked up.
+func (c *PDBClient) cachedEntryStatus(id string) (holdingsStatus, error) {
+	c.holdingsMu.Lock()
+	if c.holdings == nil {
+		c.holdings = make(map[string]*holdingsLookup)
+	}
+	lookup, ok := c.holdings[id]
+	if !ok {
+		lookup = &holdingsLookup{}
+		c.holdings[id] = lookup
+	}
+	c.holdingsMu.Unlock()
+
+	lookup.once.Do(func() {
+		lookup.status, lookup.err = c.entryStatus(id)
+	})
+	return lookup.status, lookup.err
+}
+
+// entryStatus looks up the holdings status of an entry.
+func (c *PDBClient) entryStatus(id string) (holdingsStatus, error) {
+	if short, ok := classicPDBId(id); ok {
+		id = short
+	}
+	url := strings.ReplaceAll(c.holdingsURL, "{ID}", strings.ToUpper(id))
+
+	var status holdingsStatus
+	err := c.withRetries(func() error {
+		resp, err := c.client.Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return &httpStatusError{
+				url:        url,
+				statusCode: resp.StatusCode,
+				retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			}
+		}
+		return json.NewDecoder(resp.Body).Decode(&status)
+	})
+	return status, err
+}
+
+// obsoleteFormat returns the format of an entry in the obsolete part of the
+// wwPDB archive, which RCSB's download service does not serve.
+func (f structureFormat) obsoleteFormat() (structureFormat, error) {
+	if !strings.HasPrefix(f.archivePath, divided) {
+		return structureFormat{}, fmt.Errorf("obsolete entries are not archived as %s: %w", f.name, errFormatUnsupported)
+	}
+	obsolete := f
+	obsolete.remoteExtension = ""
+	obsolete.archivePath = "data/structures/obsolete/" + strings.TrimPrefix(f.archivePath, divided)
+	return obsolete, nil
+}
+
+// fetchObsolete downloads the coordinates of an obsolete entry from the
+// archive of obsolete entries.
+func (c *PDBClient) fetchObsolete(id, content, outputPath string) (string, error) {
+	if content != contentCoords || c.assembly != 0 {
+		return "", fmt.Errorf("only coordinates of the asymmetric unit are archived for obsolete entries: %w", errNotFound)
+	}
+
+	formats := c.formats
+	if len(formats) == 0 {
+		formats = []structureFormat{formatPDB}
+	}
+	var err error
+	for _, format := range formats {
+		var obsolete structureFormat
+		obsolete, err = format.obsoleteFormat()
+		if err != nil {
+			continue
+		}
+		var filename string
+		filename, err = c.fetchFromMirrors(id, obsolete, outputPath)
+		if err == nil {
+			return filename, nil
+		}
+	}
+	return "", err
+}
+
+// handleObsolete checks whether an entry whose download failed is obsolete
+// and, if so, applies the client's policy for obsolete entries to result.
+// Every content of an obsolete entry is handled alike, except that only the
+// coordinates are in the archive of obsolete entries.
+func (c *PDBClient) handleObsolete(result fetchResult, outputPath string) fetchResult {
+	if c.holdingsURL == "" {
+		return result
+	}
+	status, err := c.cachedEntryStatus(result.id)
+	if err != nil || !status.obsolete() {
+		return result
+	}
+
+	result.obsolete = true
+	replacedBy, _ := normalizePDBId(status.Combined.ReplacedBy)
+
+	switch c.obsoletePolicy {
+	case obsoleteFetchArchive:
+		if result.content != contentCoords {
+			break
+		}
+		var filename string
+		filename, result.err = c.fetchObsolete(result.id, result.content, outputPath)
+		result.filenames = nil
+		if result.err == nil {
+			result.filenames = []string{filename}
+		}
+		result.status = classifyFetchError(result.err)
+		return result
+	case obsoleteFollow:
+		if replacedBy != "" {
+			result.replacedBy = replacedBy
+			result.filenames, result.err = c.fetchContent(replacedBy, result.content, outputPath)
+			result.status = classifyFetchError(result.err)
+			return result
+		}
+	}
+
+	result.status = statusObsolete
+	result.filenames = nil
+	result.err = fmt.Errorf("obsolete entry")
+	if replacedBy != "" {
+		result.err = fmt.Errorf("obsolete entry, replaced by %s", replacedBy)
+	}
+	return result
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+)
+
+func Test_structureFormat_obsoleteFormat(t *testing.T) {
+	format, err := formatCIF.obsoleteFormat()
+	if err != nil {
+		t.Fatalf("obsoleteFormat() returned error: %v", err)
+	}
+	if format.remoteExtension != "" || format.archivePath != "data/structures/obsolete/mmCIF/{hash}/{id}.cif.gz" {
+		t.Errorf("Unexpected obsolete format: %+v", format)
+	}
+	if _, err := formatBCIF.obsoleteFormat(); err == nil {
+		t.Error("Expected error for a format without an archive path")
+	}
+}
+
+func Test_fetchPDB_obsolete(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/holdings/1HHB":
+			fmt.Fprint(w, `{"rcsb_id": "1HHB", "rcsb_repository_holdings_combined": {"id_code_replaced_by_latest": "2HHB", "status": "REMOVED", "status_code": "OBS"}}`)
+			return
+		case "/holdings/1ABC":
+			fmt.Fprint(w, `{"rcsb_id": "1ABC", "rcsb_repository_holdings_combined": {"status": "REMOVED", "status_code": "OBS"}}`)
+			return
+		case "/2hhb.pdb.gz", "/data/structures/obsolete/pdb/hh/pdb1hhb.ent.gz":
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			gz.Write([]byte("HEADER    dummy pdb data"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	logger = log.New(ioutil.Discard, "", 0)
+
+	testCases := []struct {
+		policy     string
+		status     fetchStatus
+		file       string
+		replacedBy string
+	}{
+		{obsoleteSkip, statusObsolete, "", ""},
+		{obsoleteFetchArchive, statusOK, "1HHB.pdb", ""},
+		{obsoleteFollow, statusOK, "2HHB.pdb", "2hhb"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.policy, func(t *testing.T) {
+			outputPath := t.TempDir()
+			client := &PDBClient{
+				mirrors:        testMirrors(ts),
+				client:         &http.Client{},
+				holdingsURL:    ts.URL + "/holdings/{ID}",
+				obsoletePolicy: tc.policy,
+			}
+
+			results := fetchPDB([]string{"1hhb", "1abc", "9xyz"}, outputPath, client, 1)
+
+			r := results[0]
+			if r.status != tc.status || r.replacedBy != tc.replacedBy || !r.obsolete {
+				t.Errorf("Expected (%s, replaced by %q), got (%s, replaced by %q): %v", tc.status, tc.replacedBy, r.status, r.replacedBy, r.err)
+			}
+			if tc.file != "" && !equalStringSlices(r.filenames, []string{path.Join(outputPath, tc.file)}) {
+				t.Errorf("Expected file %s, got %v", tc.file, r.filenames)
+			}
+			if tc.policy == obsoleteSkip && r.err.Error() != "obsolete entry, replaced by 2hhb" {
+				t.Errorf("Expected the replacement in the error, got %v", r.err)
+			}
+
+			// Obsolete without a replacement and nowhere to be found.
+			if tc.policy != obsoleteFetchArchive && results[1].status != statusObsolete {
+				t.Errorf("Expected 1abc to be obsolete, got %s: %v", results[1].status, results[1].err)
+			}
+			// Not obsolete, just missing.
+			if results[2].status != statusNotFound || results[2].obsolete {
+				t.Errorf("Expected 9xyz not found, got %s: %v", results[2].status, results[2].err)
+			}
+		})
+	}
+}
+
+func Test_fetchPDB_obsolete_auxiliary(t *testing.T) {
+	requests := make(map[string]int)
+	var mu sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/holdings/1HHB":
+			fmt.Fprint(w, `{"rcsb_id": "1HHB", "rcsb_repository_holdings_combined": {"id_code_replaced_by_latest": "2HHB", "status": "REMOVED", "status_code": "OBS"}}`)
+		case "/2hhb.pdb.gz", "/data/structures/obsolete/pdb/hh/pdb1hhb.ent.gz":
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			gz.Write([]byte("HEADER    dummy pdb data"))
+		case "/2hhb-sf.cif.gz":
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			gz.Write([]byte("data_r2hhbsf\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	logger = log.New(ioutil.Discard, "", 0)
+
+	// The statuses of structure factors, coordinates and validation reports.
+	testCases := []struct {
+		policy     string
+		statuses   []fetchStatus
+		replacedBy string
+	}{
+		{obsoleteSkip, []fetchStatus{statusObsolete, statusObsolete, statusObsolete}, ""},
+		{obsoleteFetchArchive, []fetchStatus{statusObsolete, statusOK, statusObsolete}, ""},
+		{obsoleteFollow, []fetchStatus{statusOK, statusOK, statusNotFound}, "2hhb"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.policy, func(t *testing.T) {
+			mu.Lock()
+			requests = make(map[string]int)
+			mu.Unlock()
+
+			client := &PDBClient{
+				mirrors:        testMirrors(ts),
+				client:         &http.Client{},
+				holdingsURL:    ts.URL + "/holdings/{ID}",
+				obsoletePolicy: tc.policy,
+				contents:       []string{"sf", contentCoords, "validation"},
+			}
+			results := fetchPDB([]string{"1hhb"}, t.TempDir(), client, 2)
+			if len(results) != 3 {
+				t.Fatalf("Expected 3 results, got %d", len(results))
+			}
+
+			for i, r := range results {
+				if r.status != tc.statuses[i] || r.replacedBy != tc.replacedBy || !r.obsolete {
+					t.Errorf("Expected %s to be %s (replaced by %q), got %s (replaced by %q): %v",
+						r.content, tc.statuses[i], tc.replacedBy, r.status, r.replacedBy, r.err)
+				}
+			}
+			if requests["/holdings/1HHB"] != 1 {
+				t.Errorf("Expected one holdings lookup, got %d", requests["/holdings/1HHB"])
+			}
+		})
+	}
+}
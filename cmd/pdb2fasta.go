@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"kirill/pkg/structure"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	sequenceSourceSeqres = "seqres"
+	sequenceSourceAtom   = "atom"
+)
+
+// isStructureFilename reports whether a file in a directory given to
+// pdb2fasta holds coordinates. Structure factors are mmCIF files too but
+// have none.
+func isStructureFilename(name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".gz")
+	if strings.HasSuffix(name, "-sf.cif") {
+		return false
+	}
+	return strings.HasSuffix(name, ".pdb") || strings.HasSuffix(name, ".ent") || strings.HasSuffix(name, ".cif")
+}
+
+// structureFiles expands directories among the arguments to the structure
+// files in them, in name order.
+func structureFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && isStructureFilename(entry.Name()) {
+				files = append(files, path.Join(arg, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// fastaRecord is the sequence of one chain.
+type fastaRecord struct {
+	name     string
+	mol      string
+	sequence string
+	length   int
+}
+
+// seqresRecords returns the full sequences of the chains of s.
+func seqresRecords(s *structure.Structure, entry string) []fastaRecord {
+	var records []fastaRecord
+	for _, seq := range s.Sequences {
+		sequence, mol := s.OneLetterSequence(seq.Residues)
+		records = append(records, fastaRecord{entry + "_" + seq.ChainID, mol, sequence, len(seq.Residues)})
+	}
+	return records
+}
+
+// observedRecords returns the sequences of the residues with coordinates in
+// the first model of s. Missing residues, found from gaps in the residue
+// numbering, are filled in with gap, one per residue.
+func observedRecords(s *structure.Structure, entry string, gap string) []fastaRecord {
+	if len(s.Models) == 0 {
+		return nil
+	}
+
+	var records []fastaRecord
+	for _, c := range s.Models[0].Chains {
+		residues := s.PolymerResidues(c)
+		if len(residues) == 0 {
+			continue
+		}
+
+		names := make([]string, len(residues))
+		for i, r := range residues {
+			names[i] = r.Name
+		}
+		codes, mol := s.OneLetterSequence(names)
+
+		var sequence strings.Builder
+		for i, r := range residues {
+			if i > 0 && gap != "" {
+				if missing := r.SeqNum - residues[i-1].SeqNum - 1; missing > 0 {
+					sequence.WriteString(strings.Repeat(gap, missing))
+				}
+			}
+			sequence.WriteByte(codes[i])
+		}
+		records = append(records, fastaRecord{entry + "_" + c.ID, mol, sequence.String(), len(residues)})
+	}
+	return records
+}
+
+// writeFASTA writes records with headers such as
+// >1ABC_A mol:protein length:141.
+func writeFASTA(w io.Writer, records []fastaRecord) error {
+	writer := bufio.NewWriter(w)
+	for _, r := range records {
+		fmt.Fprintf(writer, ">%s mol:%s length:%d\n%s\n", r.name, r.mol, r.length, r.sequence)
+	}
+	return writer.Flush()
+}
+
+var pdb2fastaCmd = &cobra.Command{
+	Use:   "pdb2fasta [structure files or directories]",
+	Short: "Write the chain sequences of structures as FASTA",
+	Long: `pdb2fasta reads PDB or mmCIF files, gzipped or not, and writes the sequences of
+their polymer chains as multi-FASTA with headers such as
+>1ABC_A mol:protein length:141. Directories are searched for structure files,
+so a whole fetchpdb output directory can be given at once.
+
+--source seqres (the default) writes the full sequences from SEQRES records
+or _pdbx_poly_seq_scheme, including residues without coordinates. Files
+without them fall back to the observed sequences. --source atom writes the
+observed sequences of the residues with coordinates in the first model,
+filling in every residue missing from the numbering with --gap, "-" by
+default; an empty --gap joins the observed residues.
+
+Modified residues are written as their standard parents, e.g.
+selenomethionine (MSE) as M, using MODRES records or
+_pdbx_struct_mod_residue and a table of common modifications. Unknown
+residues are written as X, or N in nucleic acids.
+
+Example usage:
+
+1. Write the SEQRES sequences of all downloaded entries:
+   kirill pdb2fasta structures -o sequences.fasta
+
+2. Write observed sequences with missing residues as X:
+   kirill pdb2fasta 1ABC.cif --source atom --gap X
+
+Sequences go to standard output unless -o is given, and the log to
+standard error and pdb2fasta.log next to the output.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputFilename, _ := cmd.Flags().GetString("output")
+		source, _ := cmd.Flags().GetString("source")
+		gap, _ := cmd.Flags().GetString("gap")
+
+		var logFile *os.File
+		var err error
+
+		logPath := "pdb2fasta"
+		if outputFilename != "-" {
+			logPath = path.Join(path.Dir(outputFilename), "pdb2fasta")
+		}
+		logger, logFile, err = getLoggerTo(logPath, os.Stderr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		logger.Println(getCommandLine())
+
+		if source != sequenceSourceSeqres && source != sequenceSourceAtom {
+			logger.Fatalf("unknown sequence source %q, expected seqres or atom", source)
+		}
+		files, err := structureFiles(args)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+
+		output := os.Stdout
+		if outputFilename != "-" {
+			output, err = os.Create(outputFilename)
+			if err != nil {
+				logger.Fatalln(err)
+			}
+			defer output.Close()
+		}
+
+		failed := 0
+		sequences := 0
+		for _, filename := range files {
+			s, _, err := structure.ReadFile(filename)
+			if err != nil {
+				logger.Println(err)
+				failed++
+				continue
+			}
+
+			entry := strings.ToUpper(s.ID)
+			if entry == "" {
+				entry = structureBaseName(filename)
+			}
+			var records []fastaRecord
+			if source == sequenceSourceSeqres {
+				records = seqresRecords(s, entry)
+				if len(records) == 0 {
+					logger.Printf("%s has no SEQRES records, writing observed sequences", filename)
+				}
+			}
+			if len(records) == 0 {
+				records = observedRecords(s, entry, gap)
+			}
+			if len(records) == 0 {
+				logger.Printf("%s has no polymer chains", filename)
+				continue
+			}
+
+			if err := writeFASTA(output, records); err != nil {
+				logger.Fatalln(err)
+			}
+			sequences += len(records)
+		}
+		logger.Printf("Wrote %d sequences from %d of %d files", sequences, len(files)-failed, len(files))
+
+		if failed > 0 {
+			output.Close()
+			logFile.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pdb2fastaCmd)
+
+	pdb2fastaCmd.Flags().StringP("output", "o", "-", "Output FASTA file, - for standard output")
+	pdb2fastaCmd.Flags().StringP("source", "s", sequenceSourceSeqres, "Sequences to write: seqres (full) or atom (observed)")
+	pdb2fastaCmd.Flags().StringP("gap", "", "-", "With --source atom, written for every missing residue")
+}
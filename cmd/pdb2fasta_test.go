@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"kirill/pkg/structure"
+)
+
+const testFastaPDB = `HEADER    TEST                                    01-JAN-00   1ABC              
+SEQRES   1 A    6  MET GLY MSE SER ALA LYS                                      
+ATOM      1  CA  MET A   1       1.000   1.000   1.000  1.00 10.00           C  
+ATOM      2  CA  GLY A   2       2.000   1.000   1.000  1.00 10.00           C  
+HETATM    3  CA  MSE A   3       3.000   1.000   1.000  1.00 10.00           C  
+ATOM      4  CA  LYS A   6       4.000   1.000   1.000  1.00 10.00           C  
+HETATM    5  O   HOH A 201       6.000   1.000   1.000  1.00 10.00           O  
+HETATM    6  O   HOH W 202       7.000   1.000   1.000  1.00 10.00           O  
+END
+`
+
+func Test_fastaRecords(t *testing.T) {
+	s, _, err := structure.Read(strings.NewReader(testFastaPDB))
+	if err != nil {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		records  []fastaRecord
+		expected string
+	}{
+		{"SEQRES", seqresRecords(s, "1ABC"), ">1ABC_A mol:protein length:6\nMGMSAK\n"},
+		{"Observed with gaps", observedRecords(s, "1ABC", "-"), ">1ABC_A mol:protein length:4\nMGM--K\n"},
+		{"Observed without gaps", observedRecords(s, "1ABC", ""), ">1ABC_A mol:protein length:4\nMGMK\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := writeFASTA(&b, tc.records); err != nil {
+				t.Fatalf("writeFASTA() returned error: %v", err)
+			}
+			if b.String() != tc.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tc.expected, b.String())
+			}
+		})
+	}
+}
+
+func Test_structureFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"2DEF.cif", "1ABC.pdb", "1ABC-sf.cif", "1ABC.mr", "3GHI.cif.gz", "fetchpdb_report.tsv", "fetchpdb.log"} {
+		if err := ioutil.WriteFile(path.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(path.Join(dir, "sub.pdb"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	single := path.Join(dir, "1ABC.mr")
+
+	files, err := structureFiles([]string{dir, single})
+	if err != nil {
+		t.Fatalf("structureFiles() returned error: %v", err)
+	}
+	expected := []string{path.Join(dir, "1ABC.pdb"), path.Join(dir, "2DEF.cif"), path.Join(dir, "3GHI.cif.gz"), single}
+	if !equalStringSlices(files, expected) {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+
+	if _, err := structureFiles([]string{path.Join(dir, "missing.pdb")}); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
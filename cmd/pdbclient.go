@@ -0,0 +1,550 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"kirill/pkg/structure"
+)
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+var (
+	errNotFound       = errors.New("entry not found")
+	errInvalidContent = errors.New("downloaded file does not match the requested format")
+)
+
+// httpStatusError is returned by PDBClient.fetch when the server answers with
+// anything other than 200 OK. 404 and 410 match errNotFound via errors.Is.
+type httpStatusError struct {
+	url        string
+	statusCode int
+	retryAfter time.Duration
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("GET %s: %d %s", e.url, e.statusCode, http.StatusText(e.statusCode))
+}
+
+func (e *httpStatusError) Is(target error) bool {
+	return target == errNotFound &&
+		(e.statusCode == http.StatusNotFound || e.statusCode == http.StatusGone)
+}
+
+// transient reports whether repeating the same request later may succeed.
+func (e *httpStatusError) transient() bool {
+	return e.statusCode >= 500 ||
+		e.statusCode == http.StatusTooManyRequests ||
+		e.statusCode == http.StatusRequestTimeout
+}
+
+// isTransient reports whether err is a temporary failure such as a server
+// error, rate limiting or a dropped connection, as opposed to a missing entry
+// or a corrupt file. A connection reset while the body is read shows up as a
+// truncated transfer, an unexpected EOF from the body or from gzip.
+func isTransient(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.transient()
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date. It returns zero if the header is missing or malformed.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+const maxBackoff = 5 * time.Minute
+
+type PDBClient struct {
+	// mirrors are tried in order for every entry until one of them succeeds.
+	// An empty list means RCSB only.
+	mirrors []pdbMirror
+	client  *http.Client
+
+	// formats are tried in order until one of them exists for the entry.
+	// An empty list means legacy PDB only.
+	formats []structureFormat
+
+	// assembly selects a biological assembly instead of the asymmetric
+	// unit when positive. allAssemblies downloads every assembly.
+	assembly int
+
+	// contents lists what to download for every entry: contentCoords and
+	// the names of auxiliary formats. An empty list means coordinates only.
+	contents []string
+
+	// manifest, if set, records every written file. With skipExisting,
+	// files already listed in it are not downloaded again.
+	manifest     *fetchManifest
+	skipExisting bool
+
+	// extendedNames names output files by extended PDB ID even for entries
+	// that have a classic ID.
+	extendedNames bool
+
+	// rejected maps entries excluded by an entryFilter to the reason why.
+	// They are reported as filtered instead of being downloaded.
+	rejected map[string]string
+
+	// holdingsURL, if set, is asked whether entries that could not be
+	// downloaded are obsolete; obsoletePolicy says what to do with them.
+	// Statuses are looked up once per entry and kept in holdings.
+	holdingsURL    string
+	obsoletePolicy string
+	holdingsMu     sync.Mutex
+	holdings       map[string]*holdingsLookup
+
+	// selections maps entries to the chains and residue ranges extracted
+	// into files of their own once the coordinates are downloaded.
+	selections map[string][]structure.Selection
+
+	// normalizeID, if set, validates raw ID tokens in place of
+	// normalizePDBIdToken, for databases that are not keyed by PDB ID.
+	// auxiliary then lists the formats behind its content types, and
+	// filePrefix is prepended to every local file name.
+	normalizeID func(token string) (string, error)
+	auxiliary   []structureFormat
+	filePrefix  string
+
+	// progress, if set, is called with the number of bytes received, before
+	// decompression, as downloads proceed. It may be called from several
+	// workers at once.
+	progress func(n int64)
+
+	// retries is the number of additional attempts made after a transient
+	// failure. Delays start at backoff and double on each attempt.
+	retries int
+	backoff time.Duration
+	sleep   func(time.Duration)
+}
+
+// retryDelay returns how long to wait before retrying after the given zero-based
+// attempt failed with err. A Retry-After header takes precedence over the
+// exponential backoff, which is jittered to spread out parallel workers.
+func (c *PDBClient) retryDelay(attempt int, err error) time.Duration {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && statusErr.retryAfter > 0 {
+		if statusErr.retryAfter > maxBackoff {
+			return maxBackoff
+		}
+		return statusErr.retryAfter
+	}
+
+	delay := c.backoff << attempt
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// localFilename returns the name under which an entry is saved, e.g. 1ABC.cif.
+func (c *PDBClient) localFilename(id string, format structureFormat) string {
+	if c.extendedNames {
+		id = extendedPDBId(id)
+	}
+	return c.filePrefix + strings.ToUpper(id) + format.localExtension
+}
+
+// normalize validates a raw ID token and returns the ID to download.
+func (c *PDBClient) normalize(token string) (string, error) {
+	if c.normalizeID != nil {
+		return c.normalizeID(token)
+	}
+	return normalizePDBIdToken(token)
+}
+
+// auxiliaryFormat returns the format of a content type other than
+// coordinates.
+func (c *PDBClient) auxiliaryFormat(content string) (structureFormat, bool) {
+	if c.auxiliary == nil {
+		return auxiliaryFormat(content)
+	}
+	for _, format := range c.auxiliary {
+		if format.name == content {
+			return format, true
+		}
+	}
+	return structureFormat{}, false
+}
+
+// assemblyFormats returns the client's formats for the given assembly,
+// leaving out formats in which assemblies are not distributed.
+func (c *PDBClient) assemblyFormats(assembly int) ([]structureFormat, error) {
+	formats := c.formats
+	if len(formats) == 0 {
+		formats = []structureFormat{formatPDB}
+	}
+
+	var result []structureFormat
+	var err error
+	for _, format := range formats {
+		var f structureFormat
+		f, err = format.forAssembly(assembly)
+		if err == nil {
+			result = append(result, f)
+		}
+	}
+	if len(result) == 0 {
+		return nil, err
+	}
+	return result, nil
+}
+
+// existing returns the path of a previously downloaded file for id if the
+// manifest lists it in one of the client's formats and the file on disk still
+// has the recorded size.
+func (c *PDBClient) existing(id string, outputPath string) (string, bool) {
+	if c.assembly == allAssemblies {
+		return "", false
+	}
+	return c.existingAssembly(id, c.assembly, outputPath)
+}
+
+func (c *PDBClient) existingAssembly(id string, assembly int, outputPath string) (string, bool) {
+	formats, err := c.assemblyFormats(assembly)
+	if err != nil {
+		return "", false
+	}
+	return c.existingFile(id, formats, outputPath)
+}
+
+// existingContent is like existing for a content type other than
+// coordinates.
+func (c *PDBClient) existingContent(id, content, outputPath string) (string, bool) {
+	if content == contentCoords {
+		return c.existing(id, outputPath)
+	}
+	format, ok := c.auxiliaryFormat(content)
+	if !ok {
+		return "", false
+	}
+	return c.existingFile(id, []structureFormat{format}, outputPath)
+}
+
+func (c *PDBClient) existingFile(id string, formats []structureFormat, outputPath string) (string, bool) {
+	if c.manifest == nil || !c.skipExisting {
+		return "", false
+	}
+
+	for _, format := range formats {
+		file := c.localFilename(id, format)
+		entry, ok := c.manifest.lookup(file)
+		if !ok {
+			continue
+		}
+		filename := path.Join(outputPath, file)
+		if info, err := os.Stat(filename); err == nil && info.Size() == entry.size {
+			return filename, true
+		}
+	}
+	return "", false
+}
+
+// fetch downloads a single entry in the first available format, retrying
+// transient failures. It returns one file name per assembly if all
+// assemblies are requested.
+func (c *PDBClient) fetch(id string, outputPath string) ([]string, error) {
+	if c.assembly == allAssemblies {
+		return c.fetchAllAssemblies(id, outputPath)
+	}
+	filename, err := c.fetchAssembly(id, c.assembly, outputPath)
+	if err != nil {
+		return nil, err
+	}
+	return []string{filename}, nil
+}
+
+// fetchContent downloads one content type for an entry. Coordinates follow
+// the client's formats and assembly; auxiliary files are fetched as is.
+func (c *PDBClient) fetchContent(id, content, outputPath string) ([]string, error) {
+	if content == contentCoords {
+		return c.fetch(id, outputPath)
+	}
+	format, ok := c.auxiliaryFormat(content)
+	if !ok {
+		return nil, fmt.Errorf("unknown content type: %s", content)
+	}
+	filename, err := c.fetchFromMirrors(id, format, outputPath)
+	if err != nil {
+		return nil, err
+	}
+	return []string{filename}, nil
+}
+
+// fetchAllAssemblies downloads assemblies 1, 2, ... until one is not found.
+// Assemblies already in the manifest are not downloaded again with
+// skipExisting.
+func (c *PDBClient) fetchAllAssemblies(id string, outputPath string) ([]string, error) {
+	var filenames []string
+	for n := 1; ; n++ {
+		if filename, ok := c.existingAssembly(id, n, outputPath); ok {
+			filenames = append(filenames, filename)
+			continue
+		}
+
+		filename, err := c.fetchAssembly(id, n, outputPath)
+		if errors.Is(err, errNotFound) && n > 1 {
+			return filenames, nil
+		}
+		if err != nil {
+			return filenames, err
+		}
+		filenames = append(filenames, filename)
+	}
+}
+
+func (c *PDBClient) fetchAssembly(id string, assembly int, outputPath string) (string, error) {
+	formats, err := c.assemblyFormats(assembly)
+	if err != nil {
+		return "", err
+	}
+
+	for _, format := range formats {
+		var filename string
+		filename, err = c.fetchFromMirrors(id, format, outputPath)
+		if !errors.Is(err, errNotFound) {
+			return filename, err
+		}
+	}
+	if assembly > 0 {
+		return "", fmt.Errorf("assembly %d: %w", assembly, err)
+	}
+	return "", err
+}
+
+// fetchFromMirrors tries every mirror in turn. The result is errNotFound only
+// if no mirror has the entry; otherwise the first other failure is returned,
+// since it is more likely to explain why the download did not succeed.
+func (c *PDBClient) fetchFromMirrors(id string, format structureFormat, outputPath string) (string, error) {
+	mirrors := c.mirrors
+	if len(mirrors) == 0 {
+		mirrors = defaultMirrors()
+	}
+
+	if isExtendedPDBId(id) && format.name == formatPDB.name {
+		return "", fmt.Errorf("legacy PDB files do not exist for extended ID %s: %w", id, errNotFound)
+	}
+
+	var firstErr, notFoundErr error
+	for _, mirror := range mirrors {
+		url, err := mirror.url(id, format)
+		if errors.Is(err, errFormatUnsupported) {
+			continue
+		}
+
+		filename, err := c.fetchWithRetries(id, url, format, outputPath)
+		switch {
+		case err == nil:
+			return filename, nil
+		case errors.Is(err, errNotFound):
+			if notFoundErr == nil {
+				notFoundErr = err
+			}
+		case firstErr == nil:
+			firstErr = fmt.Errorf("%s: %w", mirror.name, err)
+		}
+	}
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+	if notFoundErr != nil {
+		return "", notFoundErr
+	}
+	return "", fmt.Errorf("%s %w from any mirror", format.name, errFormatUnsupported)
+}
+
+func (c *PDBClient) fetchWithRetries(id, url string, format structureFormat, outputPath string) (string, error) {
+	var filename string
+	err := c.withRetries(func() error {
+		var err error
+		filename, err = c.fetchOnce(id, url, format, outputPath)
+		return err
+	})
+	return filename, err
+}
+
+// withRetries calls do until it succeeds, fails with an error that is not
+// transient, or the client runs out of retries.
+func (c *PDBClient) withRetries(do func() error) error {
+	sleep := c.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := do()
+		if err == nil || !isTransient(err) {
+			return err
+		}
+		if attempt >= c.retries {
+			if attempt > 0 {
+				err = fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+			}
+			return err
+		}
+		sleep(c.retryDelay(attempt, err))
+	}
+}
+
+func (c *PDBClient) fetchOnce(id, url string, format structureFormat, outputPath string) (string, error) {
+	filename := path.Join(outputPath, c.localFilename(id, format))
+
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &httpStatusError{
+			url:        url,
+			statusCode: resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	var received io.Reader = resp.Body
+	if c.progress != nil {
+		received = &progressReader{reader: resp.Body, report: c.progress}
+	}
+
+	body, err := decompress(received)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	// Only the beginning of the file is held in memory for validation; the
+	// rest is streamed to disk.
+	reader := bufio.NewReaderSize(body, validationPeekSize)
+	head, err := reader.Peek(validationPeekSize)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if err := format.validate(head); err != nil {
+		return "", err
+	}
+	checksum, size, err := writeFileAtomic(filename, reader)
+	if err != nil {
+		return "", err
+	}
+
+	if c.manifest != nil {
+		err := c.manifest.add(manifestEntry{
+			id:        id,
+			format:    format.name,
+			file:      path.Base(filename),
+			size:      size,
+			sha256:    checksum,
+			fetchedAt: time.Now(),
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return filename, nil
+}
+
+// validationPeekSize is how much of a decompressed file is inspected by
+// structureFormat.validate before it is written.
+const validationPeekSize = 4096
+
+// progressReader reports the number of bytes read through it.
+type progressReader struct {
+	reader io.Reader
+	report func(n int64)
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	if n > 0 {
+		p.report(int64(n))
+	}
+	return n, err
+}
+
+// decompress returns a reader for the content of r, which is gunzipped if it
+// is a gzip stream. The PDB archive serves gzipped files, while other
+// databases such as AlphaFold DB serve them as is.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	if magic, _ := buffered.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+		return ioutil.NopCloser(buffered), nil
+	}
+	return gzip.NewReader(buffered)
+}
+
+// writeFileAtomic streams r to a temporary file next to filename and renames
+// it into place, so an interrupted run never leaves a partial file behind. It
+// returns the SHA-256 checksum and size of the written data.
+func writeFileAtomic(filename string, r io.Reader) (string, int64, error) {
+	tmpFile, err := ioutil.TempFile(path.Dir(filename), "."+path.Base(filename)+".*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, hash), r)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := tmpFile.Chmod(0644); err != nil {
+		return "", 0, err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmpFile.Name(), filename); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// newHTTPClient returns an HTTP client that can also read file:// URLs, so a
+// local copy of the archive can be used as a mirror.
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,427 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_PDBClient_fetch_status(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		body       string
+		notFound   bool
+		transient  bool
+	}{
+		{
+			name:       "Not found",
+			statusCode: http.StatusNotFound,
+			notFound:   true,
+		},
+		{
+			name:       "Gone",
+			statusCode: http.StatusGone,
+			notFound:   true,
+		},
+		{
+			name:       "Service unavailable",
+			statusCode: http.StatusServiceUnavailable,
+			transient:  true,
+		},
+		{
+			name:       "Too many requests",
+			statusCode: http.StatusTooManyRequests,
+			transient:  true,
+		},
+		{
+			name:       "Forbidden",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "HTML instead of structure",
+			statusCode: http.StatusOK,
+			body:       "<html>maintenance</html>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statusCode)
+				gz := gzip.NewWriter(w)
+				defer gz.Close()
+				gz.Write([]byte(tc.body))
+			}))
+			defer ts.Close()
+
+			client := &PDBClient{
+				mirrors: testMirrors(ts),
+				client:  &http.Client{},
+			}
+
+			_, err := client.fetch("1abc", t.TempDir())
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if errors.Is(err, errNotFound) != tc.notFound {
+				t.Errorf("Expected not found: %v, got error: %v", tc.notFound, err)
+			}
+			if isTransient(err) != tc.transient {
+				t.Errorf("Expected transient: %v, got error: %v", tc.transient, err)
+			}
+		})
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 5, 5, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		header   string
+		expected time.Duration
+	}{
+		{
+			name:     "Missing",
+			header:   "",
+			expected: 0,
+		},
+		{
+			name:     "Seconds",
+			header:   "120",
+			expected: 2 * time.Minute,
+		},
+		{
+			name:     "HTTP date",
+			header:   "Fri, 05 May 2023 12:00:30 GMT",
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "Date in the past",
+			header:   "Fri, 05 May 2023 11:00:00 GMT",
+			expected: 0,
+		},
+		{
+			name:     "Garbage",
+			header:   "soon",
+			expected: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			delay := parseRetryAfter(tc.header, now)
+			if delay != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, delay)
+			}
+		})
+	}
+}
+
+func Test_PDBClient_retryDelay(t *testing.T) {
+	client := &PDBClient{backoff: time.Second}
+	transientErr := &httpStatusError{statusCode: http.StatusServiceUnavailable}
+
+	for attempt := 0; attempt < 4; attempt++ {
+		base := time.Second << attempt
+		delay := client.retryDelay(attempt, transientErr)
+		if delay < base/2 || delay > base {
+			t.Errorf("Attempt %d: expected delay in [%s, %s], got %s", attempt, base/2, base, delay)
+		}
+	}
+
+	if delay := client.retryDelay(30, transientErr); delay > maxBackoff {
+		t.Errorf("Expected delay capped at %s, got %s", maxBackoff, delay)
+	}
+
+	retryAfterErr := &httpStatusError{statusCode: http.StatusTooManyRequests, retryAfter: 7 * time.Second}
+	if delay := client.retryDelay(0, retryAfterErr); delay != 7*time.Second {
+		t.Errorf("Expected Retry-After delay of 7s, got %s", delay)
+	}
+}
+
+func Test_PDBClient_fetch_retries(t *testing.T) {
+	testCases := []struct {
+		name          string
+		failures      int32
+		failureStatus int
+		retries       int
+		expectedCalls int32
+		success       bool
+	}{
+		{
+			name:          "Recovers after transient failures",
+			failures:      2,
+			failureStatus: http.StatusServiceUnavailable,
+			retries:       3,
+			expectedCalls: 3,
+			success:       true,
+		},
+		{
+			name:          "Gives up after retries are exhausted",
+			failures:      10,
+			failureStatus: http.StatusBadGateway,
+			retries:       2,
+			expectedCalls: 3,
+			success:       false,
+		},
+		{
+			name:          "Does not retry missing entries",
+			failures:      10,
+			failureStatus: http.StatusNotFound,
+			retries:       3,
+			expectedCalls: 1,
+			success:       false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) <= tc.failures {
+					w.Header().Set("Retry-After", "3")
+					w.WriteHeader(tc.failureStatus)
+					return
+				}
+				gz := gzip.NewWriter(w)
+				defer gz.Close()
+				gz.Write([]byte("HEADER    dummy pdb data"))
+			}))
+			defer ts.Close()
+
+			var sleeps []time.Duration
+			client := &PDBClient{
+				mirrors: testMirrors(ts),
+				client:  &http.Client{},
+				retries: tc.retries,
+				backoff: time.Millisecond,
+				sleep:   func(d time.Duration) { sleeps = append(sleeps, d) },
+			}
+
+			_, err := client.fetch("1abc", t.TempDir())
+			if (err == nil) != tc.success {
+				t.Errorf("Expected success: %v, got error: %v", tc.success, err)
+			}
+			if calls != tc.expectedCalls {
+				t.Errorf("Expected %d requests, got %d", tc.expectedCalls, calls)
+			}
+			for _, d := range sleeps {
+				if d != 3*time.Second {
+					t.Errorf("Expected Retry-After delay of 3s, got %s", d)
+				}
+			}
+			if tc.expectedCalls > 1 && !tc.success && !strings.Contains(err.Error(), "attempts") {
+				t.Errorf("Expected error to mention attempts, got: %v", err)
+			}
+		})
+	}
+}
+
+func Test_PDBClient_fetch_truncated(t *testing.T) {
+	var content bytes.Buffer
+	gz := gzip.NewWriter(&content)
+	gz.Write([]byte("HEADER    dummy pdb data" + strings.Repeat("\nATOM      1  CA  GLY A   1", 200)))
+	gz.Close()
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			w.Write(content.Bytes())
+			return
+		}
+		// Drop the connection halfway through the body.
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n", content.Len())
+		buf.Write(content.Bytes()[:content.Len()/2])
+		buf.Flush()
+	}))
+	defer ts.Close()
+
+	var sleeps int
+	client := &PDBClient{
+		mirrors: testMirrors(ts),
+		client:  &http.Client{},
+		retries: 2,
+		backoff: time.Millisecond,
+		sleep:   func(time.Duration) { sleeps++ },
+	}
+
+	filenames, err := client.fetch("1abc", t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected the truncated transfer to be retried, got error: %v", err)
+	}
+	filename := filenames[0]
+	if calls != 2 || sleeps != 1 {
+		t.Errorf("Expected 2 requests with a backoff between them, got %d requests and %d sleeps", calls, sleeps)
+	}
+	if data, err := ioutil.ReadFile(filename); err != nil || !strings.HasPrefix(string(data), "HEADER") {
+		t.Errorf("Expected the complete file, got %v", err)
+	}
+}
+
+func Test_PDBClient_fetch_fallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".pdb.gz") {
+			http.NotFound(w, r)
+			return
+		}
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write([]byte("data_4V6X\n#\n"))
+	}))
+	defer ts.Close()
+
+	client := &PDBClient{
+		mirrors: testMirrors(ts),
+		client:  &http.Client{},
+	}
+
+	if _, err := client.fetch("4v6x", t.TempDir()); !errors.Is(err, errNotFound) {
+		t.Errorf("Expected not found without fallback, got: %v", err)
+	}
+
+	client.formats = []structureFormat{formatPDB, formatCIF}
+	filenames, err := client.fetch("4v6x", t.TempDir())
+	if err != nil {
+		t.Fatalf("fetch() returned error: %v", err)
+	}
+	filename := filenames[0]
+	if !strings.HasSuffix(filename, "4V6X.cif") {
+		t.Errorf("Expected mmCIF output file, got %s", filename)
+	}
+}
+
+func Test_PDBClient_fetch_streaming(t *testing.T) {
+	content := "HEADER    large structure\n" + strings.Repeat("ATOM      1  CA  GLY A   1       0.000   0.000   0.000  1.00  0.00           C\n", 20000)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write([]byte(content))
+	}))
+	defer ts.Close()
+
+	var received int64
+	client := &PDBClient{
+		mirrors:  testMirrors(ts),
+		client:   &http.Client{},
+		progress: func(n int64) { atomic.AddInt64(&received, n) },
+	}
+
+	filenames, err := client.fetch("1abc", t.TempDir())
+	if err != nil {
+		t.Fatalf("fetch() returned error: %v", err)
+	}
+	filename := filenames[0]
+
+	written, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != content {
+		t.Errorf("Expected %d bytes written, got %d", len(content), len(written))
+	}
+	if received == 0 || received >= int64(len(content)) {
+		t.Errorf("Expected progress to report compressed bytes, got %d", received)
+	}
+}
+
+func Test_PDBClient_fetch_extendedIDs(t *testing.T) {
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		if strings.HasSuffix(r.URL.Path, ".cif.gz") {
+			gz.Write([]byte("data_entry\n"))
+		} else {
+			gz.Write([]byte("HEADER    dummy pdb data"))
+		}
+	}))
+	defer ts.Close()
+
+	client := &PDBClient{
+		mirrors: testMirrors(ts),
+		client:  &http.Client{},
+		formats: []structureFormat{formatPDB, formatCIF},
+	}
+
+	filenames, err := client.fetch("pdb_0001ab9z", t.TempDir())
+	if err != nil {
+		t.Fatalf("fetch() returned error: %v", err)
+	}
+	filename := filenames[0]
+	if !strings.HasSuffix(filename, "PDB_0001AB9Z.cif") {
+		t.Errorf("Expected mmCIF file named by extended ID, got %s", filename)
+	}
+	if len(requested) != 1 || requested[0] != "/pdb_0001ab9z.cif.gz" {
+		t.Errorf("Expected a single mmCIF request, got %v", requested)
+	}
+
+	client.extendedNames = true
+	filenames, err = client.fetch("1abc", t.TempDir())
+	if err != nil {
+		t.Fatalf("fetch() returned error: %v", err)
+	}
+	filename = filenames[0]
+	if !strings.HasSuffix(filename, "PDB_00001ABC.pdb") {
+		t.Errorf("Expected file named by extended ID, got %s", filename)
+	}
+	if requested[1] != "/1abc.pdb.gz" {
+		t.Errorf("Expected classic ID in URL, got %s", requested[1])
+	}
+}
+
+func Test_PDBClient_fetch_assemblies(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path.Base(r.URL.Path) {
+		case "1abc-assembly1.cif.gz", "1abc-assembly2.cif.gz", "2def-assembly1.cif.gz":
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			gz.Write([]byte("data_assembly\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	client := &PDBClient{
+		mirrors:  testMirrors(ts),
+		client:   &http.Client{},
+		formats:  []structureFormat{formatCIF},
+		assembly: allAssemblies,
+	}
+
+	filenames, err := client.fetch("1abc", t.TempDir())
+	if err != nil {
+		t.Fatalf("fetch() returned error: %v", err)
+	}
+	if len(filenames) != 2 || !strings.HasSuffix(filenames[0], "1ABC-assembly1.cif") || !strings.HasSuffix(filenames[1], "1ABC-assembly2.cif") {
+		t.Errorf("Expected two assembly files, got %v", filenames)
+	}
+
+	if _, err := client.fetch("3ghi", t.TempDir()); !errors.Is(err, errNotFound) {
+		t.Errorf("Expected not found for entry without assemblies, got: %v", err)
+	}
+
+	client.assembly = 2
+	if _, err := client.fetch("2def", t.TempDir()); !errors.Is(err, errNotFound) || !strings.Contains(err.Error(), "assembly 2") {
+		t.Errorf("Expected assembly 2 not found, got: %v", err)
+	}
+}
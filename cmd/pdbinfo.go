@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var metadataColumns = []string{
+	"id", "title", "method", "resolution", "r_free", "deposit_date", "release_date",
+	"organisms", "polymer_entities", "chains", "ligands",
+}
+
+// formatOptionalFloat writes v, leaving the field empty for the zero value.
+func formatOptionalFloat(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// writeMetadataTable writes entries as TSV. Fields with several values, such
+// as organisms, are separated by semicolons.
+func writeMetadataTable(w io.Writer, entries []entryMetadata) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = '\t'
+	if err := writer.Write(metadataColumns); err != nil {
+		return err
+	}
+	for _, m := range entries {
+		record := []string{
+			m.id,
+			m.title,
+			strings.Join(m.methods, ";"),
+			formatOptionalFloat(m.resolution),
+			formatOptionalFloat(m.rFree),
+			m.depositDate,
+			m.releaseDate,
+			strings.Join(m.organisms, ";"),
+			strconv.Itoa(m.entities),
+			strconv.Itoa(m.chains),
+			strings.Join(m.ligands, ";"),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+var pdbinfoCmd = &cobra.Command{
+	Use:   "pdbinfo [PDB IDs or input file]",
+	Short: "Write a table of metadata for PDB entries",
+	Long: `pdbinfo looks up PDB entries in the RCSB Data API and writes an overview of
+them to pdbinfo.tsv in the output directory: title, experimental method,
+resolution, R-free, deposition and release dates, source organisms, the number
+of polymer entities and chains, and bound ligands.
+IDs are read like in fetchpdb, so the same input files work for both.
+
+Example usage:
+
+1. Describe a few entries:
+   kirill pdbinfo 4hhb 1a00
+
+2. Describe the entries of a previous fetchpdb run:
+   kirill pdbinfo structures/fetchpdb_report.tsv --column id -o structures
+
+Entries the Data API does not know, as well as invalid IDs, are logged and
+left out of the table, and the exit code is non-zero.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputPath, _ := cmd.Flags().GetString("output")
+		column, _ := cmd.Flags().GetString("column")
+		retries, _ := cmd.Flags().GetInt("retries")
+		backoff, _ := cmd.Flags().GetDuration("backoff")
+
+		var logFile *os.File
+		var err error
+
+		logPath := path.Join(outputPath, "pdbinfo")
+		logger, logFile, err = getLogger(logPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		logger.Println(getCommandLine())
+
+		tokens, err := readPDBIdTokens(args, column, os.Stdin)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+
+		failed := 0
+		var ids []string
+		for _, token := range tokens {
+			id, err := normalizePDBIdToken(token)
+			if err != nil {
+				logger.Println(err)
+				failed++
+				continue
+			}
+			ids = append(ids, id)
+		}
+
+		client := &PDBClient{
+			client:  newHTTPClient(),
+			retries: retries,
+			backoff: backoff,
+		}
+
+		start := time.Now()
+		logger.Printf("Looking up %d entries", len(ids))
+		metadata, err := client.entryMetadata(rcsbGraphQLURL, ids)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+
+		var entries []entryMetadata
+		for _, id := range ids {
+			m, ok := metadata[id]
+			if !ok {
+				logger.Printf("No metadata found for %s", id)
+				failed++
+				continue
+			}
+			entries = append(entries, m)
+		}
+
+		filename := path.Join(outputPath, "pdbinfo.tsv")
+		file, err := os.Create(filename)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		if err := writeMetadataTable(file, entries); err != nil {
+			logger.Fatalln(err)
+		}
+		if err := file.Close(); err != nil {
+			logger.Fatalln(err)
+		}
+		logger.Printf("Wrote metadata for %d of %d entries to %s in %s", len(entries), len(tokens), filename, time.Since(start).Round(time.Millisecond))
+
+		if failed > 0 {
+			logFile.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pdbinfoCmd)
+
+	pdbinfoCmd.Flags().StringP("output", "o", ".", "Output directory")
+	pdbinfoCmd.Flags().StringP("column", "c", "", "Read PDB IDs from this column (name or 1-based index) of CSV/TSV input files")
+	pdbinfoCmd.Flags().IntP("retries", "", 3, "Number of retries after a transient failure")
+	pdbinfoCmd.Flags().DurationP("backoff", "", time.Second, "Initial delay between retries, doubled on every attempt")
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_writeMetadataTable(t *testing.T) {
+	entries := []entryMetadata{
+		{
+			id:          "4hhb",
+			title:       "HUMAN DEOXYHAEMOGLOBIN",
+			methods:     []string{"X-RAY DIFFRACTION"},
+			resolution:  1.74,
+			rFree:       0.215,
+			depositDate: "1984-03-07",
+			releaseDate: "1984-07-17",
+			organisms:   []string{"Homo sapiens"},
+			entities:    2,
+			chains:      4,
+			ligands:     []string{"HEM", "PO4"},
+		},
+		{
+			id:          "2k39",
+			methods:     []string{"SOLUTION NMR"},
+			releaseDate: "2008-06-03",
+			organisms:   []string{"Escherichia coli", "Homo sapiens"},
+			entities:    1,
+			chains:      1,
+		},
+	}
+
+	var b strings.Builder
+	if err := writeMetadataTable(&b, entries); err != nil {
+		t.Fatalf("writeMetadataTable() returned error: %v", err)
+	}
+
+	expected := "id\ttitle\tmethod\tresolution\tr_free\tdeposit_date\trelease_date\torganisms\tpolymer_entities\tchains\tligands\n" +
+		"4hhb\tHUMAN DEOXYHAEMOGLOBIN\tX-RAY DIFFRACTION\t1.74\t0.215\t1984-03-07\t1984-07-17\tHomo sapiens\t2\t4\tHEM;PO4\n" +
+		"2k39\t\tSOLUTION NMR\t\t\t\t2008-06-03\tEscherichia coli;Homo sapiens\t1\t1\t\n"
+	if b.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// byteProgress counts bytes received by all download workers and
+// periodically prints the total and the average transfer rate.
+type byteProgress struct {
+	received int64
+	start    time.Time
+}
+
+func newByteProgress() *byteProgress {
+	return &byteProgress{start: time.Now()}
+}
+
+func (p *byteProgress) add(n int64) {
+	atomic.AddInt64(&p.received, n)
+}
+
+func (p *byteProgress) String() string {
+	received := atomic.LoadInt64(&p.received)
+	elapsed := time.Since(p.start).Seconds()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(received) / elapsed
+	}
+	return fmt.Sprintf("Received %s (%s/s)", formatBytes(float64(received)), formatBytes(rate))
+}
+
+// report writes the progress to w every interval until the returned function
+// is called.
+func (p *byteProgress) report(w io.Writer, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintln(w, p)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func formatBytes(n float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
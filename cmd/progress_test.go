@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_formatBytes(t *testing.T) {
+	testCases := []struct {
+		n        float64
+		expected string
+	}{
+		{n: 0, expected: "0 B"},
+		{n: 1023, expected: "1023 B"},
+		{n: 1536, expected: "1.5 KB"},
+		{n: 5 * 1024 * 1024, expected: "5.0 MB"},
+		{n: 3 * 1024 * 1024 * 1024 * 1024 * 1024, expected: "3072.0 TB"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			if result := formatBytes(tc.n); result != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+type syncBuilder struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (b *syncBuilder) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.Write(p)
+}
+
+func (b *syncBuilder) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.String()
+}
+
+func Test_byteProgress_report(t *testing.T) {
+	progress := newByteProgress()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 256; j++ {
+				progress.add(1024)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var output syncBuilder
+	stop := progress.report(&output, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	stop()
+
+	if !strings.Contains(output.String(), "Received 1.0 MB") {
+		t.Errorf("Expected progress output to contain total bytes, got %q", output.String())
+	}
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kirill/pkg/structure"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	sasaLevelAtom    = "atom"
+	sasaLevelResidue = "residue"
+)
+
+var (
+	sasaAtomColumns    = []string{"entry", "chain", "residue_number", "residue_name", "atom_name", "element", "radius", "sasa"}
+	sasaResidueColumns = []string{"entry", "chain", "residue_number", "residue_name", "sasa", "relative_sasa"}
+)
+
+// readRadiiTable returns a built-in radii table by name, or reads one from a
+// file.
+func readRadiiTable(name string) (structure.RadiiTable, error) {
+	if table, err := structure.ParseRadiiTable(name); err == nil {
+		return table, nil
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("radii table %q is neither bondi, alvarez nor a readable file: %w", name, err)
+	}
+	defer file.Close()
+	table, err := structure.ReadRadiiTable(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return table, nil
+}
+
+// unknownElements returns the elements of atoms missing from the radii
+// table, in alphabetical order.
+func unknownElements(atoms []structure.AtomArea, radii structure.RadiiTable) []string {
+	seen := make(map[string]bool)
+	var elements []string
+	for _, a := range atoms {
+		if _, ok := radii.Radius(a.Atom.Element); !ok && !seen[a.Atom.Element] {
+			seen[a.Atom.Element] = true
+			elements = append(elements, a.Atom.Element)
+		}
+	}
+	sort.Strings(elements)
+	return elements
+}
+
+func formatArea(area float64) string {
+	return strconv.FormatFloat(area, 'f', 2, 64)
+}
+
+// writeAtomAreas writes a row per atom to writer.
+func writeAtomAreas(writer *csv.Writer, entry string, atoms []structure.AtomArea) error {
+	for _, a := range atoms {
+		record := []string{
+			entry, a.Chain.ID, a.Residue.ID(), a.Residue.Name, a.Atom.Name, a.Atom.Element,
+			strconv.FormatFloat(a.Radius, 'f', 2, 64), formatArea(a.Area),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeResidueAreas writes a row per residue to writer. The relative area is
+// left empty for residues other than amino acids.
+func writeResidueAreas(writer *csv.Writer, entry string, residues []structure.ResidueArea) error {
+	for _, r := range residues {
+		relative := ""
+		if !math.IsNaN(r.Relative) {
+			relative = strconv.FormatFloat(r.Relative, 'f', 3, 64)
+		}
+		record := []string{entry, r.Chain.ID, r.Residue.ID(), r.Residue.Name, formatArea(r.Area), relative}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSASA computes the areas of s and writes them at the given level,
+// after the column names if header is set. It returns the total area and the
+// elements that had no radius.
+func writeSASA(w io.Writer, s *structure.Structure, entry, level string, opts structure.SASAOptions, header bool) (float64, []string, error) {
+	atoms, err := s.SASA(opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	total := 0.0
+	for _, a := range atoms {
+		total += a.Area
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = '\t'
+	if level == sasaLevelAtom {
+		if header {
+			writer.Write(sasaAtomColumns)
+		}
+		err = writeAtomAreas(writer, entry, atoms)
+	} else {
+		if header {
+			writer.Write(sasaResidueColumns)
+		}
+		err = writeResidueAreas(writer, entry, s.ResidueAreas(atoms))
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	writer.Flush()
+	return total, unknownElements(atoms, opts.Radii), writer.Error()
+}
+
+var sasaCmd = &cobra.Command{
+	Use:   "sasa [structure files or directories]",
+	Short: "Compute solvent accessible surface areas",
+	Long: `sasa computes the solvent accessible surface area (SASA) of the atoms in the
+first model of PDB or mmCIF files, gzipped or not, with the Shrake–Rupley
+algorithm: a probe sphere, 1.4 Å by default, is rolled over the van der Waals
+spheres of the atoms, and the exposed fraction of each atom is estimated from
+--points test points on its sphere. Atoms are computed in parallel by --jobs
+workers, one per CPU by default.
+
+--level residue (the default) writes a TSV row per residue with its SASA in Å²
+and the SASA relative to the largest one of its amino acid type (Tien et al.,
+2013), which is empty for other residues. --level atom writes a row per atom
+with its radius and SASA instead.
+
+Radii are taken by element from the table given with --radii: bondi (the
+default), alvarez, or a file with an element and a radius on every line.
+Elements not in the table get a radius of 1.8 Å and are logged. Waters and
+hydrogens are left out, the latter unless --hydrogens is given, and ligands
+too with --skip-hetero. Of alternate locations, the one with the highest
+total occupancy is used, also among residues that share a number, such as
+point mutations modeled as alternate locations. Directories are searched for
+structure files.
+
+Example usage:
+
+1. Write per-residue and relative SASA of an entry:
+   kirill sasa 1ABC.cif -o 1ABC_sasa.tsv
+
+2. Write per-atom SASA of an AlphaFold model with a larger probe and more
+   points:
+   kirill sasa AF-P69905-F1-model_v4.pdb --level atom --probe 1.6 --points 500
+
+Areas go to standard output unless -o is given, and the log to standard
+error and sasa.log next to the output.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputFilename, _ := cmd.Flags().GetString("output")
+		level, _ := cmd.Flags().GetString("level")
+		probe, _ := cmd.Flags().GetFloat64("probe")
+		points, _ := cmd.Flags().GetInt("points")
+		radiiName, _ := cmd.Flags().GetString("radii")
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		hydrogens, _ := cmd.Flags().GetBool("hydrogens")
+		skipHetero, _ := cmd.Flags().GetBool("skip-hetero")
+
+		var logFile *os.File
+		var err error
+
+		logPath := "sasa"
+		if outputFilename != "-" {
+			logPath = path.Join(path.Dir(outputFilename), "sasa")
+		}
+		logger, logFile, err = getLoggerTo(logPath, os.Stderr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		logger.Println(getCommandLine())
+
+		if level != sasaLevelResidue && level != sasaLevelAtom {
+			logger.Fatalf("unknown level %q, expected residue or atom", level)
+		}
+		radii, err := readRadiiTable(radiiName)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		opts := structure.SASAOptions{
+			Probe:      probe,
+			Points:     points,
+			Radii:      radii,
+			Jobs:       jobs,
+			Hydrogens:  hydrogens,
+			SkipHetero: skipHetero,
+		}
+		files, err := structureFiles(args)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+
+		output := os.Stdout
+		if outputFilename != "-" {
+			output, err = os.Create(outputFilename)
+			if err != nil {
+				logger.Fatalln(err)
+			}
+			defer output.Close()
+		}
+
+		failed := 0
+		header := true
+		for _, filename := range files {
+			s, _, err := structure.ReadFile(filename)
+			if err != nil {
+				logger.Println(err)
+				failed++
+				continue
+			}
+
+			entry := strings.ToUpper(s.ID)
+			if entry == "" {
+				entry = structureBaseName(filename)
+			}
+			total, unknown, err := writeSASA(output, s, entry, level, opts, header)
+			if err != nil {
+				logger.Printf("%s: %v", filename, err)
+				failed++
+				continue
+			}
+			header = false
+			if len(unknown) > 0 {
+				logger.Printf("%s: no radius for %s, using %.2f Å", filename, strings.Join(unknown, ", "), structure.UnknownElementRadius)
+			}
+			logger.Printf("%s: total SASA %.1f Å²", filename, total)
+		}
+		logger.Printf("Computed SASA of %d of %d files", len(files)-failed, len(files))
+
+		if failed > 0 {
+			output.Close()
+			logFile.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sasaCmd)
+
+	sasaCmd.Flags().StringP("output", "o", "-", "Output TSV file, - for standard output")
+	sasaCmd.Flags().StringP("level", "l", sasaLevelResidue, "Rows to write: residue or atom")
+	sasaCmd.Flags().Float64P("probe", "", structure.DefaultProbeRadius, "Probe radius in Å")
+	sasaCmd.Flags().IntP("points", "", structure.DefaultSASAPoints, "Test points per atom")
+	sasaCmd.Flags().StringP("radii", "", "bondi", "Radii table: bondi, alvarez or a file of element and radius lines")
+	sasaCmd.Flags().IntP("jobs", "j", runtime.NumCPU(), "Number of parallel workers")
+	sasaCmd.Flags().BoolP("hydrogens", "", false, "Include hydrogen atoms")
+	sasaCmd.Flags().BoolP("skip-hetero", "", false, "Leave out ligands and other HETATM residues")
+}
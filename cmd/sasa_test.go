@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"kirill/pkg/structure"
+)
+
+func Test_writeSASA(t *testing.T) {
+	s, err := structure.ReadPDB(strings.NewReader(testConvertPDB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := structure.SASAOptions{Probe: 1.4, Points: 100, Radii: structure.RadiiBondi, Jobs: 2}
+
+	testCases := []struct {
+		level    string
+		expected string
+	}{
+		{level: sasaLevelResidue, expected: "entry\tchain\tresidue_number\tresidue_name\tsasa\trelative_sasa\n1ABC\tA\t1\tGLY\t120.76\t1.161\n"},
+		{level: sasaLevelAtom, expected: "entry\tchain\tresidue_number\tresidue_name\tatom_name\telement\tradius\tsasa\n1ABC\tA\t1\tGLY\tCA\tC\t1.70\t120.76\n"},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		total, unknown, err := writeSASA(&buf, s, "1ABC", tc.level, opts, true)
+		if err != nil {
+			t.Fatalf("writeSASA(%s) returned error: %v", tc.level, err)
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("writeSASA(%s): expected\n%q, got\n%q", tc.level, tc.expected, buf.String())
+		}
+		if total < 120.7 || total > 120.8 || len(unknown) != 0 {
+			t.Errorf("writeSASA(%s): unexpected total %g and unknown elements %v", tc.level, total, unknown)
+		}
+	}
+}
+
+func Test_readRadiiTable(t *testing.T) {
+	if table, err := readRadiiTable("alvarez"); err != nil || table["C"] != 1.77 {
+		t.Errorf("Expected the alvarez table, got %v, %v", table, err)
+	}
+
+	filename := path.Join(t.TempDir(), "radii.txt")
+	if err := ioutil.WriteFile(filename, []byte("C 1.9\nO 1.4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if table, err := readRadiiTable(filename); err != nil || table["C"] != 1.9 {
+		t.Errorf("Expected radii from the file, got %v, %v", table, err)
+	}
+	if _, err := readRadiiTable("missing.txt"); err == nil {
+		t.Errorf("Expected an error for a missing table")
+	}
+}
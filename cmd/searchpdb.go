@@ -0,0 +1,418 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	rcsbSearchURL  = "https://search.rcsb.org/rcsbsearch/v2/query"
+	searchPageSize = 1000
+)
+
+// experimentalMethods maps short names accepted by --method to the values of
+// exptl.method in the PDB. Other values are passed on in upper case.
+var experimentalMethods = map[string]string{
+	"xray":    "X-RAY DIFFRACTION",
+	"x-ray":   "X-RAY DIFFRACTION",
+	"nmr":     "SOLUTION NMR",
+	"ssnmr":   "SOLID-STATE NMR",
+	"em":      "ELECTRON MICROSCOPY",
+	"cryoem":  "ELECTRON MICROSCOPY",
+	"neutron": "NEUTRON DIFFRACTION",
+	"fiber":   "FIBER DIFFRACTION",
+	"ed":      "ELECTRON CRYSTALLOGRAPHY",
+}
+
+// searchCriteria are the conditions of a searchpdb query. Zero values are
+// not part of the query; all others must hold at once.
+type searchCriteria struct {
+	text           string
+	organism       string
+	methods        []string
+	maxResolution  float64
+	releasedAfter  string
+	releasedBefore string
+	sequence       string
+	identity       float64
+	evalue         float64
+}
+
+// searchNode is a node of an RCSB search query: a terminal with a service
+// and its parameters, or a group of nodes.
+type searchNode struct {
+	Type            string       `json:"type"`
+	LogicalOperator string       `json:"logical_operator,omitempty"`
+	Nodes           []searchNode `json:"nodes,omitempty"`
+	Service         string       `json:"service,omitempty"`
+	Parameters      interface{}  `json:"parameters,omitempty"`
+}
+
+type attributeParameters struct {
+	Attribute string      `json:"attribute"`
+	Operator  string      `json:"operator"`
+	Value     interface{} `json:"value"`
+}
+
+type sequenceParameters struct {
+	EvalueCutoff   float64 `json:"evalue_cutoff"`
+	IdentityCutoff float64 `json:"identity_cutoff"`
+	SequenceType   string  `json:"sequence_type"`
+	Value          string  `json:"value"`
+}
+
+type searchRequest struct {
+	Query          searchNode `json:"query"`
+	ReturnType     string     `json:"return_type"`
+	RequestOptions struct {
+		Paginate struct {
+			Start int `json:"start"`
+			Rows  int `json:"rows"`
+		} `json:"paginate"`
+	} `json:"request_options"`
+}
+
+type searchResponse struct {
+	TotalCount int `json:"total_count"`
+	ResultSet  []struct {
+		Identifier string `json:"identifier"`
+	} `json:"result_set"`
+}
+
+func attributeNode(attribute, operator string, value interface{}) searchNode {
+	return searchNode{
+		Type:       "terminal",
+		Service:    "text",
+		Parameters: attributeParameters{attribute, operator, value},
+	}
+}
+
+// query builds the RCSB search query for the criteria.
+func (s searchCriteria) query() (searchNode, error) {
+	var nodes []searchNode
+
+	if s.text != "" {
+		nodes = append(nodes, searchNode{
+			Type:       "terminal",
+			Service:    "full_text",
+			Parameters: map[string]string{"value": s.text},
+		})
+	}
+
+	if s.organism != "" {
+		if taxonomyID, err := strconv.Atoi(s.organism); err == nil {
+			nodes = append(nodes, attributeNode("rcsb_entity_source_organism.ncbi_taxonomy_id", "equals", taxonomyID))
+		} else {
+			nodes = append(nodes, attributeNode("rcsb_entity_source_organism.scientific_name", "exact_match", s.organism))
+		}
+	}
+
+	if len(s.methods) > 0 {
+		methods := make([]string, len(s.methods))
+		for i, method := range s.methods {
+			methods[i] = experimentalMethod(method)
+		}
+		nodes = append(nodes, attributeNode("exptl.method", "in", methods))
+	}
+
+	if s.maxResolution > 0 {
+		nodes = append(nodes, attributeNode("rcsb_entry_info.resolution_combined", "less_or_equal", s.maxResolution))
+	}
+
+	for _, bound := range []struct{ date, operator string }{
+		{s.releasedAfter, "greater_or_equal"},
+		{s.releasedBefore, "less_or_equal"},
+	} {
+		if bound.date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", bound.date); err != nil {
+			return searchNode{}, fmt.Errorf("invalid release date %q, expected YYYY-MM-DD", bound.date)
+		}
+		nodes = append(nodes, attributeNode("rcsb_accession_info.initial_release_date", bound.operator, bound.date))
+	}
+
+	if s.sequence != "" {
+		if s.identity < 0 || s.identity > 1 {
+			return searchNode{}, fmt.Errorf("sequence identity must be between 0 and 1, got %g", s.identity)
+		}
+		nodes = append(nodes, searchNode{
+			Type:    "terminal",
+			Service: "sequence",
+			Parameters: sequenceParameters{
+				EvalueCutoff:   s.evalue,
+				IdentityCutoff: s.identity,
+				SequenceType:   "protein",
+				Value:          s.sequence,
+			},
+		})
+	}
+
+	switch len(nodes) {
+	case 0:
+		return searchNode{}, fmt.Errorf("no search criteria given")
+	case 1:
+		return nodes[0], nil
+	}
+	return searchNode{Type: "group", LogicalOperator: "and", Nodes: nodes}, nil
+}
+
+// isSequenceText reports whether value consists of sequence letters and
+// whitespace only.
+func isSequenceText(value string) bool {
+	for _, c := range value {
+		if !unicode.IsSpace(c) && (c > unicode.MaxASCII || !unicode.IsLetter(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// readSequenceArg returns the sequence given with --sequence, either literally
+// or as the name of a FASTA file, whose first record is used. Values of
+// letters only, and values that cannot be a file, such as sequences too long
+// for a file name, are sequences.
+func readSequenceArg(value string) (string, error) {
+	literal := strings.ToUpper(strings.Join(strings.Fields(value), ""))
+	if isSequenceText(value) {
+		return literal, nil
+	}
+	if _, err := os.Stat(value); err != nil {
+		return literal, nil
+	}
+
+	file, err := os.Open(value)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var sequence strings.Builder
+	records := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, ">") {
+			records++
+			if records > 1 {
+				break
+			}
+			continue
+		}
+		sequence.WriteString(strings.ToUpper(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if sequence.Len() == 0 {
+		return "", fmt.Errorf("%s: no sequence found", value)
+	}
+	return sequence.String(), nil
+}
+
+// search sends query to the RCSB search API at url and returns the IDs of
+// all matching entries, requesting pageSize results at a time. If limit is
+// positive, at most limit IDs are returned.
+func (c *PDBClient) search(url string, query searchNode, pageSize, limit int) ([]string, error) {
+	var ids []string
+	for {
+		rows := pageSize
+		if limit > 0 && limit-len(ids) < rows {
+			rows = limit - len(ids)
+		}
+
+		var request searchRequest
+		request.Query = query
+		request.ReturnType = "entry"
+		request.RequestOptions.Paginate.Start = len(ids)
+		request.RequestOptions.Paginate.Rows = rows
+
+		var page searchResponse
+		err := c.withRetries(func() error {
+			var err error
+			page, err = c.searchOnce(url, request)
+			return err
+		})
+		if err != nil {
+			return ids, err
+		}
+		if len(ids) == 0 {
+			logger.Printf("Search matched %d entries", page.TotalCount)
+		}
+
+		for _, result := range page.ResultSet {
+			ids = append(ids, result.Identifier)
+		}
+		if len(page.ResultSet) == 0 || len(ids) >= page.TotalCount || (limit > 0 && len(ids) >= limit) {
+			return ids, nil
+		}
+	}
+}
+
+func (c *PDBClient) searchOnce(url string, request searchRequest) (searchResponse, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return searchResponse{}, err
+	}
+
+	resp, err := c.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return searchResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		// The search API answers queries without matches with no content.
+		return searchResponse{}, nil
+	case http.StatusBadRequest:
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return searchResponse{}, fmt.Errorf("search query rejected: %s", strings.TrimSpace(string(message)))
+	default:
+		return searchResponse{}, &httpStatusError{
+			url:        url,
+			statusCode: resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	var page searchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return searchResponse{}, fmt.Errorf("reading search results: %w", err)
+	}
+	return page, nil
+}
+
+var searchpdbCmd = &cobra.Command{
+	Use:   "searchpdb",
+	Short: "Search the Protein Data Bank and write a list of PDB IDs",
+	Long: `searchpdb queries the RCSB PDB search API and writes the IDs of all matching
+entries, one per line, to standard output or the file given with --output.
+All given criteria must hold at once. The list can be passed to fetchpdb as an
+input file or piped into it directly.
+
+Example usage:
+
+1. Human hemoglobin structures solved by X-ray crystallography at 2 Å or better:
+   kirill searchpdb --text hemoglobin --organism "Homo sapiens" --method xray --max-resolution 2
+
+2. Cryo-EM structures released in 2023, saved to a file:
+   kirill searchpdb --method em --released-after 2023-01-01 --released-before 2023-12-31 -o em2023.txt
+
+3. Download every entry with a chain at least 90% identical to a sequence:
+   kirill searchpdb --sequence query.fasta --identity 0.9 | kirill fetchpdb - -o structures
+
+--method accepts exptl.method values such as "SOLUTION NMR" or the short names
+xray, nmr, ssnmr, em, neutron, fiber and ed. --organism takes a scientific name
+or an NCBI taxonomy ID. --sequence takes a protein sequence or a FASTA file.
+
+The log is written to standard error and to searchpdb.log next to the output.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		outputFilename, _ := cmd.Flags().GetString("output")
+		limit, _ := cmd.Flags().GetInt("limit")
+		retries, _ := cmd.Flags().GetInt("retries")
+		backoff, _ := cmd.Flags().GetDuration("backoff")
+		sequenceValue, _ := cmd.Flags().GetString("sequence")
+
+		var criteria searchCriteria
+		criteria.text, _ = cmd.Flags().GetString("text")
+		criteria.organism, _ = cmd.Flags().GetString("organism")
+		criteria.methods, _ = cmd.Flags().GetStringSlice("method")
+		criteria.maxResolution, _ = cmd.Flags().GetFloat64("max-resolution")
+		criteria.releasedAfter, _ = cmd.Flags().GetString("released-after")
+		criteria.releasedBefore, _ = cmd.Flags().GetString("released-before")
+		criteria.identity, _ = cmd.Flags().GetFloat64("identity")
+		criteria.evalue, _ = cmd.Flags().GetFloat64("evalue")
+
+		var logFile *os.File
+		var err error
+
+		logPath := "searchpdb"
+		if outputFilename != "-" {
+			logPath = path.Join(path.Dir(outputFilename), "searchpdb")
+		}
+		logger, logFile, err = getLoggerTo(logPath, os.Stderr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		logger.Println(getCommandLine())
+
+		if sequenceValue != "" {
+			criteria.sequence, err = readSequenceArg(sequenceValue)
+			if err != nil {
+				logger.Fatalln(err)
+			}
+		}
+		query, err := criteria.query()
+		if err != nil {
+			logger.Fatalln(err)
+		}
+
+		client := &PDBClient{
+			client:  newHTTPClient(),
+			retries: retries,
+			backoff: backoff,
+		}
+
+		start := time.Now()
+		ids, err := client.search(rcsbSearchURL, query, searchPageSize, limit)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+
+		output := os.Stdout
+		if outputFilename != "-" {
+			output, err = os.Create(outputFilename)
+			if err != nil {
+				logger.Fatalln(err)
+			}
+			defer output.Close()
+		}
+
+		writer := bufio.NewWriter(output)
+		for _, id := range ids {
+			fmt.Fprintln(writer, strings.ToLower(id))
+		}
+		if err := writer.Flush(); err != nil {
+			logger.Fatalln(err)
+		}
+
+		logger.Printf("Wrote %d PDB IDs in %s", len(ids), time.Since(start).Round(time.Millisecond))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchpdbCmd)
+
+	searchpdbCmd.Flags().StringP("output", "o", "-", "Output file for the PDB IDs, - for standard output")
+	searchpdbCmd.Flags().StringP("text", "t", "", "Full-text search terms")
+	searchpdbCmd.Flags().StringP("organism", "", "", "Source organism, as a scientific name or NCBI taxonomy ID")
+	searchpdbCmd.Flags().StringSliceP("method", "", nil, "Experimental methods, any of which may match (xray, nmr, em, ...)")
+	searchpdbCmd.Flags().Float64P("max-resolution", "", 0, "Maximum resolution in Å")
+	searchpdbCmd.Flags().StringP("released-after", "", "", "Earliest initial release date (YYYY-MM-DD)")
+	searchpdbCmd.Flags().StringP("released-before", "", "", "Latest initial release date (YYYY-MM-DD)")
+	searchpdbCmd.Flags().StringP("sequence", "", "", "Protein sequence or FASTA file to search by similarity")
+	searchpdbCmd.Flags().Float64P("identity", "", 0.9, "Minimum sequence identity (0-1) for --sequence")
+	searchpdbCmd.Flags().Float64P("evalue", "", 0.1, "Maximum E-value for --sequence")
+	searchpdbCmd.Flags().IntP("limit", "n", 0, "Write at most this many IDs (0 for all)")
+	searchpdbCmd.Flags().IntP("retries", "", 3, "Number of retries after a transient failure")
+	searchpdbCmd.Flags().DurationP("backoff", "", time.Second, "Initial delay between retries, doubled on every attempt")
+}
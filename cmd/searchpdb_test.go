@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+)
+
+func Test_searchCriteria_query(t *testing.T) {
+	testCases := []struct {
+		name     string
+		criteria searchCriteria
+		expected string
+		err      bool
+	}{
+		{
+			name:     "Full text only",
+			criteria: searchCriteria{text: "hemoglobin"},
+			expected: `{"type":"terminal","service":"full_text","parameters":{"value":"hemoglobin"}}`,
+		},
+		{
+			name:     "Organism by taxonomy ID",
+			criteria: searchCriteria{organism: "9606"},
+			expected: `{"type":"terminal","service":"text","parameters":{"attribute":"rcsb_entity_source_organism.ncbi_taxonomy_id","operator":"equals","value":9606}}`,
+		},
+		{
+			name:     "Methods and resolution",
+			criteria: searchCriteria{methods: []string{"xray", "Electron Microscopy"}, maxResolution: 2.5},
+			expected: `{"type":"group","logical_operator":"and","nodes":[` +
+				`{"type":"terminal","service":"text","parameters":{"attribute":"exptl.method","operator":"in","value":["X-RAY DIFFRACTION","ELECTRON MICROSCOPY"]}},` +
+				`{"type":"terminal","service":"text","parameters":{"attribute":"rcsb_entry_info.resolution_combined","operator":"less_or_equal","value":2.5}}]}`,
+		},
+		{
+			name:     "Release date range",
+			criteria: searchCriteria{releasedAfter: "2020-01-01", releasedBefore: "2020-12-31"},
+			expected: `{"type":"group","logical_operator":"and","nodes":[` +
+				`{"type":"terminal","service":"text","parameters":{"attribute":"rcsb_accession_info.initial_release_date","operator":"greater_or_equal","value":"2020-01-01"}},` +
+				`{"type":"terminal","service":"text","parameters":{"attribute":"rcsb_accession_info.initial_release_date","operator":"less_or_equal","value":"2020-12-31"}}]}`,
+		},
+		{
+			name:     "Sequence",
+			criteria: searchCriteria{sequence: "MVLSPADKTNVKAAW", identity: 0.9, evalue: 0.1},
+			expected: `{"type":"terminal","service":"sequence","parameters":{"evalue_cutoff":0.1,"identity_cutoff":0.9,"sequence_type":"protein","value":"MVLSPADKTNVKAAW"}}`,
+		},
+		{
+			name:     "Invalid date",
+			criteria: searchCriteria{releasedAfter: "01/01/2020"},
+			err:      true,
+		},
+		{
+			name:     "Invalid identity",
+			criteria: searchCriteria{sequence: "MVLS", identity: 90},
+			err:      true,
+		},
+		{
+			name: "No criteria",
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.criteria.query()
+			if (err != nil) != tc.err {
+				t.Fatalf("Expected error: %v, got: %v", tc.err, err)
+			}
+			if tc.err {
+				return
+			}
+			result, err := json.Marshal(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(result) != tc.expected {
+				t.Errorf("Expected query:\n%s\ngot:\n%s", tc.expected, result)
+			}
+		})
+	}
+}
+
+func Test_PDBClient_search(t *testing.T) {
+	const total = 5
+	var starts []int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request searchRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ReturnType != "entry" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "bad request")
+			return
+		}
+		if request.Query.Service == "full_text" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		start, rows := request.RequestOptions.Paginate.Start, request.RequestOptions.Paginate.Rows
+		starts = append(starts, start)
+		var results []string
+		for i := start; i < start+rows && i < total; i++ {
+			results = append(results, fmt.Sprintf(`{"identifier":"%dABC","score":1}`, i+1))
+		}
+		fmt.Fprintf(w, `{"total_count":%d,"result_set":[%s]}`, total, strings.Join(results, ","))
+	}))
+	defer ts.Close()
+
+	logger = log.New(ioutil.Discard, "", 0)
+	client := &PDBClient{client: &http.Client{}}
+	query := attributeNode("exptl.method", "in", []string{"X-RAY DIFFRACTION"})
+
+	ids, err := client.search(ts.URL, query, 2, 0)
+	if err != nil {
+		t.Fatalf("search() returned error: %v", err)
+	}
+	if expected := []string{"1ABC", "2ABC", "3ABC", "4ABC", "5ABC"}; !equalStringSlices(ids, expected) {
+		t.Errorf("Expected %v, got %v", expected, ids)
+	}
+	if expected := fmt.Sprint([]int{0, 2, 4}); fmt.Sprint(starts) != expected {
+		t.Errorf("Expected pages starting at %s, got %v", expected, starts)
+	}
+
+	ids, err = client.search(ts.URL, query, 2, 3)
+	if err != nil {
+		t.Fatalf("search() returned error: %v", err)
+	}
+	if expected := []string{"1ABC", "2ABC", "3ABC"}; !equalStringSlices(ids, expected) {
+		t.Errorf("Expected %v with limit 3, got %v", expected, ids)
+	}
+
+	ids, err = client.search(ts.URL, searchNode{Type: "terminal", Service: "full_text"}, 2, 0)
+	if err != nil || len(ids) != 0 {
+		t.Errorf("Expected no results for an empty response, got %v, %v", ids, err)
+	}
+}
+
+func Test_readSequenceArg(t *testing.T) {
+	fasta := path.Join(t.TempDir(), "query.fasta")
+	if err := ioutil.WriteFile(fasta, []byte(">sp|P69905|HBA_HUMAN\nMVLSPADKTN\nvkaaw\n>second\nGGGG\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"mvlspadktn", "MVLSPADKTN"},
+		{fasta, "MVLSPADKTNVKAAW"},
+		{strings.Repeat("mvlspadktnvkaawgkvgah", 16), strings.Repeat("MVLSPADKTNVKAAWGKVGAH", 16)},
+	}
+	for _, tc := range testCases {
+		result, err := readSequenceArg(tc.input)
+		if err != nil {
+			t.Errorf("readSequenceArg(%q) returned error: %v", tc.input, err)
+		}
+		if result != tc.expected {
+			t.Errorf("Expected %s, got %s", tc.expected, result)
+		}
+	}
+}
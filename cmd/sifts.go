@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SIFTS maps UniProt sequences to the PDB chains that contain them. The
+// chain-level table has one row per aligned segment:
+//
+//	PDB  CHAIN  SP_PRIMARY  RES_BEG  RES_END  PDB_BEG  PDB_END  SP_BEG  SP_END
+const (
+	siftsURL = "https://ftp.ebi.ac.uk/pub/databases/msd/sifts/flatfiles/tsv/pdb_chain_uniprot.tsv.gz"
+	// siftsMaxAge is how long a downloaded copy is used before it is
+	// downloaded again. SIFTS is updated weekly with the PDB.
+	siftsMaxAge = 7 * 24 * time.Hour
+)
+
+// siftsChain is a PDB chain that contains part of a UniProt sequence.
+// coverage is the number of UniProt residues aligned to the chain.
+type siftsChain struct {
+	pdb      string
+	chain    string
+	coverage int
+}
+
+// siftsMapping lists the chains of every UniProt accession in file order.
+type siftsMapping map[string][]siftsChain
+
+// readSIFTSMapping reads a pdb_chain_uniprot.tsv table, which may be gzipped.
+// Only the given accessions are kept, since the full table is large.
+func readSIFTSMapping(r io.Reader, accessions []string) (siftsMapping, error) {
+	wanted := make(map[string]bool)
+	for _, accession := range accessions {
+		wanted[accession] = true
+	}
+
+	body, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	mapping := make(siftsMapping)
+	var pdbIndex, chainIndex, accessionIndex, beginIndex, endIndex int
+	header := false
+	columnCount := 0
+
+	scanner := bufio.NewScanner(body)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+
+		if !header {
+			header = true
+			columns := []struct {
+				name  string
+				index *int
+			}{
+				{"PDB", &pdbIndex},
+				{"CHAIN", &chainIndex},
+				{"SP_PRIMARY", &accessionIndex},
+				{"SP_BEG", &beginIndex},
+				{"SP_END", &endIndex},
+			}
+			for _, column := range columns {
+				if *column.index, err = indexOf(fields, column.name); err != nil {
+					return nil, fmt.Errorf("SIFTS header: %w", err)
+				}
+			}
+			columnCount = len(fields)
+			continue
+		}
+
+		if len(fields) < columnCount {
+			return nil, fmt.Errorf("SIFTS line %d: expected %d columns, got %d", line, columnCount, len(fields))
+		}
+		accession := fields[accessionIndex]
+		if !wanted[accession] {
+			continue
+		}
+
+		begin, err := strconv.Atoi(fields[beginIndex])
+		if err != nil {
+			return nil, fmt.Errorf("SIFTS line %d: %w", line, err)
+		}
+		end, err := strconv.Atoi(fields[endIndex])
+		if err != nil {
+			return nil, fmt.Errorf("SIFTS line %d: %w", line, err)
+		}
+
+		// A chain aligns to the same sequence in several segments if it has
+		// gaps; their lengths add up.
+		pdb, chain := strings.ToLower(fields[pdbIndex]), fields[chainIndex]
+		chains := mapping[accession]
+		if n := len(chains); n > 0 && chains[n-1].pdb == pdb && chains[n-1].chain == chain {
+			chains[n-1].coverage += end - begin + 1
+			continue
+		}
+		mapping[accession] = append(chains, siftsChain{pdb: pdb, chain: chain, coverage: end - begin + 1})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !header {
+		return nil, fmt.Errorf("SIFTS file is empty")
+	}
+	return mapping, nil
+}
+
+// resolve returns PDB ID tokens with chain suffixes, e.g. 1abc_A, for the
+// chains of accession that cover at least minCoverage UniProt residues.
+func (m siftsMapping) resolve(accession string, minCoverage int) []string {
+	var tokens []string
+	for _, c := range m[accession] {
+		if c.coverage >= minCoverage {
+			tokens = append(tokens, c.pdb+"_"+c.chain)
+		}
+	}
+	return tokens
+}
+
+// siftsCacheFilename names the downloaded copy of a SIFTS table after the
+// URL it came from, e.g. pdb_chain_uniprot_1a2b3c4d.tsv, so that copies of
+// different sources are kept apart.
+func siftsCacheFilename(source string) string {
+	base := strings.TrimSuffix(path.Base(source), ".gz")
+	ext := path.Ext(base)
+	sum := sha256.Sum256([]byte(source))
+	return strings.TrimSuffix(base, ext) + "_" + hex.EncodeToString(sum[:4]) + ext
+}
+
+// openSIFTSMapping opens a local SIFTS table or, for an http(s) URL, a copy
+// downloaded to cacheDir. The copy is reused by later runs until it is older
+// than maxAge; a maxAge of 0 downloads it every time.
+func openSIFTSMapping(source, cacheDir string, maxAge time.Duration, client *http.Client) (*os.File, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(source)
+	}
+
+	filename := path.Join(cacheDir, siftsCacheFilename(source))
+	if info, err := os.Stat(filename); err == nil {
+		modified := info.ModTime().Format(time.RFC3339)
+		if maxAge > 0 && time.Since(info.ModTime()) < maxAge {
+			logger.Printf("Using SIFTS mapping downloaded to %s at %s", filename, modified)
+			return os.Open(filename)
+		}
+		logger.Printf("SIFTS mapping in %s from %s is out of date", filename, modified)
+	}
+
+	logger.Printf("Downloading SIFTS mapping from %s", source)
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{
+			url:        source,
+			statusCode: resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	body, err := decompress(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	if _, _, err := writeFileAtomic(filename, body); err != nil {
+		return nil, err
+	}
+	return os.Open(filename)
+}
+
+// resolveUniProtAccessions maps UniProt accession tokens to PDB ID tokens
+// using a SIFTS table. Invalid accessions and accessions without a matching
+// chain are logged and left out.
+func resolveUniProtAccessions(tokens []string, sifts io.Reader, minCoverage int) ([]string, error) {
+	var accessions []string
+	for _, token := range tokens {
+		accession, err := normalizeUniProtAccession(token)
+		if err != nil {
+			logger.Printf("Skipping %v", err)
+			continue
+		}
+		accessions = append(accessions, accession)
+	}
+
+	mapping, err := readSIFTSMapping(sifts, accessions)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, accession := range accessions {
+		resolved := mapping.resolve(accession, minCoverage)
+		if len(resolved) == 0 {
+			logger.Printf("No PDB chains found for %s", accession)
+			continue
+		}
+		logger.Printf("%s maps to %d PDB chains: %s", accession, len(resolved), strings.Join(resolved, ", "))
+		ids = append(ids, resolved...)
+	}
+	return ids, nil
+}
+
+// readUniProtPDBChainTokens reads UniProt accessions like readPDBIdTokens
+// reads PDB IDs and returns the PDB chains that contain them, such as 1abc_A.
+// Entries with several such chains are listed once per chain.
+func readUniProtPDBChainTokens(input []string, column string, stdin io.Reader, source, cacheDir string, maxAge time.Duration, minCoverage int) ([]string, error) {
+	accessions, err := readIdTokens(input, column, stdin, "UniProt accessions", normalizeUniProtAccession)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := openSIFTSMapping(source, cacheDir, maxAge, newHTTPClient())
+	if err != nil {
+		return nil, fmt.Errorf("SIFTS mapping: %w", err)
+	}
+	defer file.Close()
+
+	tokens, err := resolveUniProtAccessions(accessions, file, minCoverage)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Name(), err)
+	}
+	logger.Printf("Resolved %d UniProt accessions to %d PDB chains", len(accessions), len(tokens))
+	return tokens, nil
+}
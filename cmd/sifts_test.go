@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSIFTS = `# 2023/06/14 - 09:17 | PDB: 23.22 | UniProt: 2023.03
+PDB	CHAIN	SP_PRIMARY	RES_BEG	RES_END	PDB_BEG	PDB_END	SP_BEG	SP_END
+1a00	A	P69905	1	141	1	141	2	142
+1a00	C	P69905	1	141	1	141	2	142
+1a00	B	P68871	1	146	1	146	2	147
+2dn1	A	P69905	1	40	1	40	2	41
+2dn1	A	P69905	45	80	45	80	46	81
+3abc	X	Q9Y6K9	1	20	1	20	10	29
+`
+
+func Test_readSIFTSMapping(t *testing.T) {
+	mapping, err := readSIFTSMapping(strings.NewReader(testSIFTS), []string{"P69905", "P68871"})
+	if err != nil {
+		t.Fatalf("readSIFTSMapping() returned error: %v", err)
+	}
+
+	expected := []siftsChain{{"1a00", "A", 141}, {"1a00", "C", 141}, {"2dn1", "A", 76}}
+	chains := mapping["P69905"]
+	if len(chains) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, chains)
+	}
+	for i := range expected {
+		if chains[i] != expected[i] {
+			t.Errorf("Chain %d: expected %v, got %v", i, expected[i], chains[i])
+		}
+	}
+	if _, ok := mapping["Q9Y6K9"]; ok {
+		t.Error("Expected accessions that were not requested to be left out")
+	}
+
+	if _, err := readSIFTSMapping(strings.NewReader("PDB\tCHAIN\n"), nil); err == nil {
+		t.Error("Expected error for a header without SP_PRIMARY")
+	}
+}
+
+func Test_siftsMapping_resolve(t *testing.T) {
+	mapping, err := readSIFTSMapping(strings.NewReader(testSIFTS), []string{"P69905"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		minCoverage int
+		expected    []string
+	}{
+		{0, []string{"1a00_A", "1a00_C", "2dn1_A"}},
+		{100, []string{"1a00_A", "1a00_C"}},
+		{200, nil},
+	}
+	for _, tc := range testCases {
+		result := mapping.resolve("P69905", tc.minCoverage)
+		if !equalStringSlices(result, tc.expected) {
+			t.Errorf("Coverage %d: expected %v, got %v", tc.minCoverage, tc.expected, result)
+		}
+	}
+}
+
+func Test_readUniProtPDBChainTokens(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write([]byte(testSIFTS))
+	}))
+	defer ts.Close()
+
+	logger = log.New(ioutil.Discard, "", 0)
+	cacheDir := t.TempDir()
+
+	source := ts.URL + "/pdb_chain_uniprot.tsv.gz"
+	for run := 0; run < 2; run++ {
+		ids, err := readUniProtPDBChainTokens([]string{"p69905,P68871", "bogus", "Q9Y6K9"}, "", nil, source, cacheDir, time.Hour, 100)
+		if err != nil {
+			t.Fatalf("readUniProtPDBChainTokens() returned error: %v", err)
+		}
+		if expected := []string{"1a00_A", "1a00_C", "1a00_B"}; !equalStringSlices(ids, expected) {
+			t.Errorf("Expected %v, got %v", expected, ids)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected the SIFTS file to be downloaded once, got %d requests", requests)
+	}
+
+	cacheFile := path.Join(cacheDir, siftsCacheFilename(source))
+	cached, err := ioutil.ReadFile(cacheFile)
+	if err != nil || string(cached) != testSIFTS {
+		t.Errorf("Expected the decompressed SIFTS file in the cache, got %v", err)
+	}
+
+	// Copies older than the maximum age are downloaded again.
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(cacheFile, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readUniProtPDBChainTokens([]string{"P69905"}, "", nil, source, cacheDir, time.Hour, 100); err != nil {
+		t.Fatalf("readUniProtPDBChainTokens() returned error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected an out-of-date SIFTS file to be downloaded again, got %d requests", requests)
+	}
+
+	// Another source is not served from the copy of the first.
+	if _, err := readUniProtPDBChainTokens([]string{"P69905"}, "", nil, ts.URL+"/other/pdb_chain_uniprot.tsv.gz", cacheDir, time.Hour, 100); err != nil {
+		t.Fatalf("readUniProtPDBChainTokens() returned error: %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected another SIFTS URL to be downloaded, got %d requests", requests)
+	}
+}
+
+func Test_siftsCacheFilename(t *testing.T) {
+	name := siftsCacheFilename(siftsURL)
+	if !strings.HasPrefix(name, "pdb_chain_uniprot_") || !strings.HasSuffix(name, ".tsv") {
+		t.Errorf("Expected a name like pdb_chain_uniprot_<hash>.tsv, got %q", name)
+	}
+	if name == siftsCacheFilename("https://example.org/pdb_chain_uniprot.tsv.gz") {
+		t.Errorf("Expected different URLs to be cached apart")
+	}
+}
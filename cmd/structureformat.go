@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// structureFormat describes one of the kinds of files the PDB archive
+// distributes for an entry. remoteExtension is appended to the ID on RCSB's
+// download service and is empty if RCSB only serves the file from its archive.
+// archivePath is the location below pub/pdb/ in the wwPDB FTP archive layout
+// and is empty if the archive does not carry the file.
+type structureFormat struct {
+	name            string
+	remoteExtension string
+	localExtension  string
+	archivePath     string
+	validate        func(buf []byte) error
+}
+
+const divided = "data/structures/divided/"
+
+var (
+	formatPDB  = structureFormat{"pdb", ".pdb.gz", ".pdb", divided + "pdb/{hash}/pdb{id}.ent.gz", validatePDBContent}
+	formatCIF  = structureFormat{"cif", ".cif.gz", ".cif", divided + "mmCIF/{hash}/{id}.cif.gz", validateCIFContent}
+	formatBCIF = structureFormat{"bcif", ".bcif.gz", ".bcif", "", validateBCIFContent}
+	formatXML  = structureFormat{"xml", ".xml.gz", ".xml", divided + "XML/{hash}/{id}.xml.gz", validateXMLContent}
+)
+
+// Auxiliary files that can be downloaded alongside the coordinates.
+var (
+	formatSF = structureFormat{"sf", "-sf.cif.gz", "-sf.cif",
+		divided + "structure_factors/{hash}/r{id}sf.ent.gz", validateCIFContent}
+	formatMR = structureFormat{"mr", ".mr.gz", ".mr",
+		divided + "nmr_restraints/{hash}/{id}.mr.gz", validateTextContent}
+	formatCS = structureFormat{"cs", "_cs.str.gz", "_cs.str",
+		divided + "nmr_chemical_shifts/{hash}/{id}_cs.str.gz", validateCIFContent}
+	formatValidation = structureFormat{"validation", "", "_validation.pdf",
+		"validation_reports/{hash}/{id}/{id}_validation.pdf.gz", validatePDFContent}
+	formatValidationXML = structureFormat{"validation-xml", "", "_validation.xml",
+		"validation_reports/{hash}/{id}/{id}_validation.xml.gz", validateXMLContent}
+)
+
+// forAssembly returns the format of biological assembly n of an entry, or the
+// format itself for n == 0, which stands for the asymmetric unit. Assemblies
+// are only distributed as legacy PDB and mmCIF files.
+func (f structureFormat) forAssembly(n int) (structureFormat, error) {
+	if n == 0 {
+		return f, nil
+	}
+
+	assembly := f
+	switch f.name {
+	case formatPDB.name:
+		assembly.remoteExtension = fmt.Sprintf(".pdb%d.gz", n)
+		assembly.archivePath = fmt.Sprintf("data/biounit/PDB/divided/{hash}/{id}.pdb%d.gz", n)
+	case formatCIF.name:
+		assembly.remoteExtension = fmt.Sprintf("-assembly%d.cif.gz", n)
+		assembly.archivePath = fmt.Sprintf("data/assemblies/mmCIF/divided/{hash}/{id}-assembly%d.cif.gz", n)
+	default:
+		return structureFormat{}, fmt.Errorf("biological assemblies are not available as %s: %w", f.name, errFormatUnsupported)
+	}
+	assembly.localExtension = fmt.Sprintf("-assembly%d%s", n, f.localExtension)
+	return assembly, nil
+}
+
+// allAssemblies requests every biological assembly of an entry.
+const allAssemblies = -1
+
+// parseAssembly reads the --assembly option: empty for the asymmetric unit,
+// a positive assembly number, or "all".
+func parseAssembly(value string) (int, error) {
+	switch strings.ToLower(value) {
+	case "", "0":
+		return 0, nil
+	case "all":
+		return allAssemblies, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid assembly %q: expected a positive number or \"all\"", value)
+	}
+	return n, nil
+}
+
+var structureFormats = []structureFormat{formatPDB, formatCIF, formatBCIF, formatXML}
+
+func parseStructureFormat(name string) (structureFormat, error) {
+	for _, format := range structureFormats {
+		if strings.EqualFold(format.name, name) {
+			return format, nil
+		}
+	}
+	return structureFormat{}, fmt.Errorf("unknown structure format: %s", name)
+}
+
+// pdbRecordNames are record types that may open a legacy PDB file.
+var pdbRecordNames = []string{
+	"HEADER", "OBSLTE", "TITLE", "SPLIT", "CAVEAT", "COMPND", "SOURCE",
+	"KEYWDS", "EXPDTA", "AUTHOR", "REMARK", "CRYST1", "MODEL", "ATOM", "HETATM",
+}
+
+// The validate functions do a cheap sanity check that a downloaded file is in
+// the expected format rather than, for example, an HTML error page.
+
+func trimmedContent(buf []byte) ([]byte, error) {
+	content := bytes.TrimLeft(buf, " \t\r\n")
+	if len(content) == 0 {
+		return nil, fmt.Errorf("%w: empty file", errInvalidContent)
+	}
+	return content, nil
+}
+
+func validatePDBContent(buf []byte) error {
+	content, err := trimmedContent(buf)
+	if err != nil {
+		return err
+	}
+	for _, record := range pdbRecordNames {
+		if bytes.HasPrefix(content, []byte(record)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no PDB records found", errInvalidContent)
+}
+
+func validateCIFContent(buf []byte) error {
+	content, err := trimmedContent(buf)
+	if err != nil {
+		return err
+	}
+	// Comment lines may precede the first data block.
+	for bytes.HasPrefix(content, []byte("#")) {
+		end := bytes.IndexByte(content, '\n')
+		if end == -1 {
+			break
+		}
+		content = bytes.TrimLeft(content[end+1:], " \t\r\n")
+	}
+	if !bytes.HasPrefix(content, []byte("data_")) {
+		return fmt.Errorf("%w: no mmCIF data block found", errInvalidContent)
+	}
+	return nil
+}
+
+// validateBCIFContent checks that buf starts with a MessagePack map, which is
+// the top-level object of every BinaryCIF file.
+func validateBCIFContent(buf []byte) error {
+	if len(buf) == 0 {
+		return fmt.Errorf("%w: empty file", errInvalidContent)
+	}
+	first := buf[0]
+	if first&0xf0 == 0x80 || first == 0xde || first == 0xdf {
+		return nil
+	}
+	return fmt.Errorf("%w: not a BinaryCIF file", errInvalidContent)
+}
+
+func validatePDFContent(buf []byte) error {
+	if !bytes.HasPrefix(buf, []byte("%PDF")) {
+		return fmt.Errorf("%w: not a PDF file", errInvalidContent)
+	}
+	return nil
+}
+
+// validateTextContent accepts any non-empty file that does not look like an
+// HTML page, for formats such as NMR restraints that have no fixed header.
+func validateTextContent(buf []byte) error {
+	content, err := trimmedContent(buf)
+	if err != nil {
+		return err
+	}
+	lower := bytes.ToLower(content)
+	if bytes.HasPrefix(lower, []byte("<!doctype html")) || bytes.HasPrefix(lower, []byte("<html")) {
+		return fmt.Errorf("%w: got an HTML page", errInvalidContent)
+	}
+	return nil
+}
+
+func validateJSONContent(buf []byte) error {
+	content, err := trimmedContent(buf)
+	if err != nil {
+		return err
+	}
+	if content[0] != '[' && content[0] != '{' {
+		return fmt.Errorf("%w: not a JSON file", errInvalidContent)
+	}
+	return nil
+}
+
+func validateXMLContent(buf []byte) error {
+	content, err := trimmedContent(buf)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(content, []byte("<?xml")) {
+		return fmt.Errorf("%w: not a PDBML file", errInvalidContent)
+	}
+	return nil
+}
+
+// contentCoords selects the coordinate files chosen with --format and
+// --assembly. All other content types map to a single auxiliary format.
+const contentCoords = "coords"
+
+var auxiliaryFormats = []structureFormat{formatSF, formatMR, formatCS, formatValidation, formatValidationXML}
+
+// contentAliases expand to several content types.
+var contentAliases = map[string][]string{
+	"nmr": {formatMR.name, formatCS.name},
+}
+
+func auxiliaryFormat(content string) (structureFormat, bool) {
+	for _, format := range auxiliaryFormats {
+		if format.name == content {
+			return format, true
+		}
+	}
+	return structureFormat{}, false
+}
+
+// parseContents reads the --content option into a list of content types,
+// expanding aliases and dropping duplicates.
+func parseContents(values []string) ([]string, error) {
+	var contents []string
+	seen := make(map[string]bool)
+	add := func(content string) {
+		if !seen[content] {
+			seen[content] = true
+			contents = append(contents, content)
+		}
+	}
+
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if expanded, ok := contentAliases[value]; ok {
+			for _, content := range expanded {
+				add(content)
+			}
+			continue
+		}
+		if _, ok := auxiliaryFormat(value); !ok && value != contentCoords {
+			return nil, fmt.Errorf("unknown content type: %s", value)
+		}
+		add(value)
+	}
+	if len(contents) == 0 {
+		contents = []string{contentCoords}
+	}
+	return contents, nil
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_parseStructureFormat(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+		err      bool
+	}{
+		{name: "pdb", expected: ".pdb.gz"},
+		{name: "CIF", expected: ".cif.gz"},
+		{name: "bcif", expected: ".bcif.gz"},
+		{name: "xml", expected: ".xml.gz"},
+		{name: "mol2", err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			format, err := parseStructureFormat(tc.name)
+			if (err != nil) != tc.err {
+				t.Errorf("Expected error: %v, got: %v", tc.err, err)
+			}
+			if format.remoteExtension != tc.expected {
+				t.Errorf("Expected extension %q, got %q", tc.expected, format.remoteExtension)
+			}
+		})
+	}
+}
+
+func Test_structureFormat_validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		format  structureFormat
+		content string
+		valid   bool
+	}{
+		{
+			name:    "PDB header",
+			format:  formatPDB,
+			content: "HEADER    HYDROLASE                               01-JAN-00   1ABC\n",
+			valid:   true,
+		},
+		{
+			name:    "PDB without header",
+			format:  formatPDB,
+			content: "ATOM      1  N   MET A   1      11.104  13.207   2.100  1.00 20.00           N\n",
+			valid:   true,
+		},
+		{
+			name:    "mmCIF as PDB",
+			format:  formatPDB,
+			content: "data_1ABC\n#\n_entry.id 1ABC\n",
+			valid:   false,
+		},
+		{
+			name:    "mmCIF",
+			format:  formatCIF,
+			content: "data_1ABC\n#\n_entry.id 1ABC\n",
+			valid:   true,
+		},
+		{
+			name:    "mmCIF with leading comment",
+			format:  formatCIF,
+			content: "# generated\ndata_1ABC\n",
+			valid:   true,
+		},
+		{
+			name:    "BinaryCIF",
+			format:  formatBCIF,
+			content: "\x83\xa7version",
+			valid:   true,
+		},
+		{
+			name:    "PDBML",
+			format:  formatXML,
+			content: "<?xml version=\"1.0\" encoding=\"UTF-8\" ?>\n<PDBx:datablock>",
+			valid:   true,
+		},
+		{
+			name:    "Validation report",
+			format:  formatValidation,
+			content: "%PDF-1.5\n",
+			valid:   true,
+		},
+		{
+			name:    "NMR restraints",
+			format:  formatMR,
+			content: "*HEADER    STRUCTURAL GENOMICS\n",
+			valid:   true,
+		},
+		{
+			name:    "HTML instead of NMR restraints",
+			format:  formatMR,
+			content: "<html><body>Not Found</body></html>",
+			valid:   false,
+		},
+		{
+			name:    "HTML error page",
+			format:  formatXML,
+			content: "<!DOCTYPE html><html><body>Not Found</body></html>",
+			valid:   false,
+		},
+		{
+			name:    "Empty",
+			format:  formatPDB,
+			content: "\n\n",
+			valid:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.format.validate([]byte(tc.content))
+			if (err == nil) != tc.valid {
+				t.Errorf("Expected valid: %v, got error: %v", tc.valid, err)
+			}
+			if err != nil && !errors.Is(err, errInvalidContent) {
+				t.Errorf("Expected errInvalidContent, got: %v", err)
+			}
+		})
+	}
+}
+
+func Test_parseAssembly(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected int
+		err      bool
+	}{
+		{value: "", expected: 0},
+		{value: "2", expected: 2},
+		{value: "ALL", expected: allAssemblies},
+		{value: "-1", err: true},
+		{value: "first", err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			n, err := parseAssembly(tc.value)
+			if (err != nil) != tc.err {
+				t.Errorf("Expected error: %v, got: %v", tc.err, err)
+			}
+			if n != tc.expected {
+				t.Errorf("Expected %d, got %d", tc.expected, n)
+			}
+		})
+	}
+}
+
+func Test_structureFormat_forAssembly(t *testing.T) {
+	pdb, err := formatPDB.forAssembly(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pdb.remoteExtension != ".pdb1.gz" || pdb.localExtension != "-assembly1.pdb" {
+		t.Errorf("Unexpected PDB assembly extensions: %q, %q", pdb.remoteExtension, pdb.localExtension)
+	}
+
+	cif, err := formatCIF.forAssembly(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cif.remoteExtension != "-assembly2.cif.gz" || cif.localExtension != "-assembly2.cif" {
+		t.Errorf("Unexpected mmCIF assembly extensions: %q, %q", cif.remoteExtension, cif.localExtension)
+	}
+
+	if _, err := formatXML.forAssembly(1); !errors.Is(err, errFormatUnsupported) {
+		t.Errorf("Expected errFormatUnsupported for PDBML assemblies, got: %v", err)
+	}
+}
+
+func Test_parseContents(t *testing.T) {
+	testCases := []struct {
+		name     string
+		values   []string
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "Default",
+			values:   nil,
+			expected: []string{"coords"},
+		},
+		{
+			name:     "Aliases and duplicates",
+			values:   []string{"coords", "NMR", "cs", "validation"},
+			expected: []string{"coords", "mr", "cs", "validation"},
+		},
+		{
+			name:   "Unknown",
+			values: []string{"coords", "maps"},
+			err:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			contents, err := parseContents(tc.values)
+			if (err != nil) != tc.err {
+				t.Errorf("Expected error: %v, got: %v", tc.err, err)
+			}
+			if !equalStringSlices(contents, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, contents)
+			}
+		})
+	}
+}
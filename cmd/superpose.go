@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"kirill/pkg/structure"
+
+	"github.com/spf13/cobra"
+)
+
+// superposeOptions are how atoms of the two structures are paired.
+type superposeOptions struct {
+	atoms       structure.AtomSet
+	align       bool
+	mobileChain string
+	targetChain string
+}
+
+// superposeResult describes a superposition for the log.
+type superposeResult struct {
+	rmsd     float64
+	atoms    int
+	residues int
+}
+
+// chainPairs pairs the chains of the first models of mobile and target. The
+// chains given in opts are paired with each other, or with the chain of the
+// same ID if only one is given. Otherwise chains with the same ID are paired,
+// or the only polymer chains of both if no IDs are shared.
+func chainPairs(mobile, target *structure.Structure, opts superposeOptions) ([][2]*structure.Chain, error) {
+	if len(mobile.Models) == 0 || len(target.Models) == 0 {
+		return nil, fmt.Errorf("no atoms to superpose")
+	}
+	mobileModel, targetModel := mobile.Models[0], target.Models[0]
+
+	if opts.mobileChain != "" || opts.targetChain != "" {
+		mobileChain, targetChain := opts.mobileChain, opts.targetChain
+		if mobileChain == "" {
+			mobileChain = targetChain
+		}
+		if targetChain == "" {
+			targetChain = mobileChain
+		}
+		m, t := mobileModel.Chain(mobileChain), targetModel.Chain(targetChain)
+		if m == nil {
+			return nil, fmt.Errorf("chain %s not found in the mobile structure", mobileChain)
+		}
+		if t == nil {
+			return nil, fmt.Errorf("chain %s not found in the target structure", targetChain)
+		}
+		return [][2]*structure.Chain{{m, t}}, nil
+	}
+
+	var pairs [][2]*structure.Chain
+	for _, m := range mobileModel.Chains {
+		if t := targetModel.Chain(m.ID); t != nil {
+			pairs = append(pairs, [2]*structure.Chain{m, t})
+		}
+	}
+	if len(pairs) > 0 {
+		return pairs, nil
+	}
+
+	mobilePolymers, targetPolymers := polymerChains(mobile), polymerChains(target)
+	if len(mobilePolymers) == 1 && len(targetPolymers) == 1 {
+		return [][2]*structure.Chain{{mobilePolymers[0], targetPolymers[0]}}, nil
+	}
+	return nil, fmt.Errorf("no chains with the same ID, set --mobile-chain and --target-chain")
+}
+
+// polymerChains returns the chains of the first model of s with polymer
+// residues.
+func polymerChains(s *structure.Structure) []*structure.Chain {
+	var chains []*structure.Chain
+	for _, c := range s.Models[0].Chains {
+		if len(s.PolymerResidues(c)) > 0 {
+			chains = append(chains, c)
+		}
+	}
+	return chains
+}
+
+// alignResidues pairs the polymer residues of two chains by aligning their
+// sequences.
+func alignResidues(mobile *structure.Structure, mobileChain *structure.Chain, target *structure.Structure, targetChain *structure.Chain) []structure.ResiduePair {
+	mobileResidues := mobile.PolymerResidues(mobileChain)
+	targetResidues := target.PolymerResidues(targetChain)
+	sequence := func(s *structure.Structure, residues []*structure.Residue) string {
+		names := make([]string, len(residues))
+		for i, r := range residues {
+			names[i] = r.Name
+		}
+		codes, _ := s.OneLetterSequence(names)
+		return codes
+	}
+
+	var pairs []structure.ResiduePair
+	for _, p := range structure.AlignSequences(sequence(mobile, mobileResidues), sequence(target, targetResidues)) {
+		pairs = append(pairs, structure.ResiduePair{Mobile: mobileResidues[p[0]], Target: targetResidues[p[1]]})
+	}
+	return pairs
+}
+
+// superposeStructures moves every model of mobile onto the first model of
+// target, fitting the paired atoms of the first models.
+func superposeStructures(mobile, target *structure.Structure, opts superposeOptions) (superposeResult, error) {
+	chains, err := chainPairs(mobile, target, opts)
+	if err != nil {
+		return superposeResult{}, err
+	}
+
+	var residues []structure.ResiduePair
+	for _, pair := range chains {
+		if opts.align {
+			residues = append(residues, alignResidues(mobile, pair[0], target, pair[1])...)
+		} else {
+			residues = append(residues, structure.PairByNumber(mobile.PolymerResidues(pair[0]), target.PolymerResidues(pair[1]))...)
+		}
+	}
+
+	mobileAtoms, targetAtoms := structure.PairAtoms(residues, opts.atoms)
+	transform, rmsd, err := structure.Superpose(mobileAtoms, targetAtoms)
+	if err != nil {
+		if len(residues) == 0 && !opts.align {
+			err = fmt.Errorf("%w; no residue numbers are shared, try --align", err)
+		}
+		return superposeResult{}, err
+	}
+	mobile.Transform(transform)
+	return superposeResult{rmsd: rmsd, atoms: len(mobileAtoms), residues: len(residues)}, nil
+}
+
+// superposeFile superposes the structure in mobileFile onto the one in
+// targetFile and writes it to output in the given format, or in the format
+// of mobileFile if it is empty.
+func superposeFile(mobileFile, targetFile, output string, opts superposeOptions, format structure.Format) (superposeResult, error) {
+	mobile, mobileFormat, err := structure.ReadFile(mobileFile)
+	if err != nil {
+		return superposeResult{}, err
+	}
+	target, _, err := structure.ReadFile(targetFile)
+	if err != nil {
+		return superposeResult{}, err
+	}
+	if format == "" {
+		format = mobileFormat
+	}
+
+	result, err := superposeStructures(mobile, target, opts)
+	if err != nil {
+		return result, fmt.Errorf("%s onto %s: %w", mobileFile, targetFile, err)
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return result, err
+	}
+	if err := structure.Write(file, mobile, format); err != nil {
+		file.Close()
+		os.Remove(output)
+		return result, fmt.Errorf("%s: %w", mobileFile, err)
+	}
+	return result, file.Close()
+}
+
+var superposeCmd = &cobra.Command{
+	Use:   "superpose [mobile structure file] [target structure file]",
+	Short: "Superpose one structure onto another and report the RMSD",
+	Long: `superpose moves the mobile structure onto the target with the rotation and
+translation that minimize the RMSD between paired atoms (the Kabsch
+superposition), and writes the moved structure to the output directory as
+<name>_superposed.<ext>. Input can be in PDB or mmCIF format, gzipped or not.
+The RMSD and the number of paired atoms are written to the log.
+
+Atoms are paired in residues of chains with the same ID, or between
+--mobile-chain and --target-chain. If the structures share no chain IDs and
+each has a single polymer chain, those are paired. --atoms selects the atoms
+of each residue pair: ca (the default), backbone (N, CA, C and O) or heavy
+(all but hydrogens, in residues of the same type).
+
+Residues are paired by residue number unless --align is given, which pairs
+them by aligning the chain sequences instead. Use it when the numbering
+differs, e.g. between an AlphaFold model numbered from the start of the
+UniProt sequence and a PDB entry of one domain.
+
+The fit uses the first model of both structures; all models of the mobile
+structure are moved.
+
+Example usage:
+
+1. Superpose an AlphaFold model onto chain A of a PDB entry:
+   kirill superpose AF-P69905-F1-model_v4.pdb 1ABC.cif --target-chain A --align
+
+2. Compare two entries over backbone atoms, writing mmCIF:
+   kirill superpose 2DEF.pdb 1ABC.pdb --atoms backbone --to cif -o superposed`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputPath, _ := cmd.Flags().GetString("output")
+		atoms, _ := cmd.Flags().GetString("atoms")
+		align, _ := cmd.Flags().GetBool("align")
+		mobileChain, _ := cmd.Flags().GetString("mobile-chain")
+		targetChain, _ := cmd.Flags().GetString("target-chain")
+		to, _ := cmd.Flags().GetString("to")
+
+		var logFile *os.File
+		var err error
+
+		logPath := path.Join(outputPath, "superpose")
+		logger, logFile, err = getLogger(logPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		logger.Println(getCommandLine())
+
+		opts := superposeOptions{align: align, mobileChain: mobileChain, targetChain: targetChain}
+		if opts.atoms, err = structure.ParseAtomSet(atoms); err != nil {
+			logger.Fatalln(err)
+		}
+		var format structure.Format
+		if to != "" {
+			if format, err = structure.ParseFormat(to); err != nil {
+				logger.Fatalln(err)
+			}
+		}
+
+		mobileFile, targetFile := args[0], args[1]
+		ext := filepath.Ext(strings.TrimSuffix(mobileFile, ".gz"))
+		if format != "" {
+			ext = format.Extension()
+		}
+		output := path.Join(outputPath, structureBaseName(mobileFile)+"_superposed"+ext)
+
+		result, err := superposeFile(mobileFile, targetFile, output, opts, format)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		logger.Printf("Superposed %s onto %s using %d %s atoms of %d residue pairs: RMSD %.3f Å", mobileFile, targetFile, result.atoms, opts.atoms, result.residues, result.rmsd)
+		logger.Printf("Wrote %s", output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(superposeCmd)
+
+	superposeCmd.Flags().StringP("output", "o", ".", "Output directory")
+	superposeCmd.Flags().StringP("atoms", "a", string(structure.AtomsCA), "Atoms to superpose: ca, backbone or heavy")
+	superposeCmd.Flags().BoolP("align", "", false, "Pair residues by sequence alignment instead of residue number")
+	superposeCmd.Flags().StringP("mobile-chain", "", "", "Chain of the mobile structure to fit")
+	superposeCmd.Flags().StringP("target-chain", "", "", "Chain of the target structure to fit")
+	superposeCmd.Flags().StringP("to", "t", "", "Output format: pdb or cif; the mobile structure's format by default")
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"math"
+	"path"
+	"strings"
+	"testing"
+
+	"kirill/pkg/structure"
+)
+
+const testSuperposeTarget = `HEADER    TEST                                    01-JAN-00   1ABC              
+ATOM      1  CA  MET A   1       0.000   0.000   0.000  1.00 10.00           C  
+ATOM      2  CA  LYS A   2       3.800   0.000   0.000  1.00 10.00           C  
+ATOM      3  CA  VAL A   3       5.000   3.600   0.000  1.00 10.00           C  
+ATOM      4  CA  GLY A   4       7.100   4.200   3.100  1.00 10.00           C  
+END
+`
+
+// testSuperposeMobile is the target moved by 10 Å along x, in chain B with
+// an extra residue at the start and numbered from 10.
+const testSuperposeMobile = `HEADER    TEST                                    01-JAN-00   2DEF              
+ATOM      1  CA  SER B  10       6.200   0.000   0.000  1.00 10.00           C  
+ATOM      2  CA  MET B  11      10.000   0.000   0.000  1.00 10.00           C  
+ATOM      3  CA  LYS B  12      13.800   0.000   0.000  1.00 10.00           C  
+ATOM      4  CA  VAL B  13      15.000   3.600   0.000  1.00 10.00           C  
+ATOM      5  CA  GLY B  14      17.100   4.200   3.100  1.00 10.00           C  
+END
+`
+
+func Test_superposeFile(t *testing.T) {
+	dir := t.TempDir()
+	mobileFile, targetFile := path.Join(dir, "2DEF.pdb"), path.Join(dir, "1ABC.pdb")
+	if err := ioutil.WriteFile(mobileFile, []byte(testSuperposeMobile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(targetFile, []byte(testSuperposeTarget), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := path.Join(dir, "2DEF_superposed.pdb")
+
+	_, err := superposeFile(mobileFile, targetFile, output, superposeOptions{atoms: structure.AtomsCA}, "")
+	if err == nil || !strings.Contains(err.Error(), "--align") {
+		t.Errorf("Expected an error suggesting --align, got %v", err)
+	}
+
+	result, err := superposeFile(mobileFile, targetFile, output, superposeOptions{atoms: structure.AtomsCA, align: true}, "")
+	if err != nil {
+		t.Fatalf("superposeFile() returned error: %v", err)
+	}
+	if result.atoms != 4 || result.residues != 4 || result.rmsd > 1e-3 {
+		t.Errorf("Expected 4 atoms superposed with RMSD 0, got %+v", result)
+	}
+
+	s, _, err := structure.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := s.Models[0].Chains[0].Residues[1].Atom("CA"); a == nil || math.Abs(a.X) > 1e-3 || math.Abs(a.Y) > 1e-3 {
+		t.Errorf("Expected MET 11 to be moved to the origin, got %+v", a)
+	}
+
+	_, err = superposeFile(mobileFile, targetFile, output, superposeOptions{atoms: structure.AtomsCA, mobileChain: "C"}, "")
+	if err == nil {
+		t.Errorf("Expected an error for a missing chain")
+	}
+}
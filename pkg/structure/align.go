@@ -0,0 +1,157 @@
+package structure
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AtomSet selects the atoms of paired residues that are superposed.
+type AtomSet string
+
+const (
+	AtomsCA       AtomSet = "ca"
+	AtomsBackbone AtomSet = "backbone"
+	// AtomsHeavy pairs all atoms other than hydrogens by name, in residues
+	// of the same type.
+	AtomsHeavy AtomSet = "heavy"
+)
+
+var backboneAtoms = []string{"N", "CA", "C", "O"}
+
+// ParseAtomSet parses an atom set name as used on the command line.
+func ParseAtomSet(name string) (AtomSet, error) {
+	switch set := AtomSet(strings.ToLower(name)); set {
+	case AtomsCA, AtomsBackbone, AtomsHeavy:
+		return set, nil
+	}
+	return "", fmt.Errorf("unknown atom set %q, expected ca, backbone or heavy", name)
+}
+
+// ResiduePair is a residue of the mobile structure and its counterpart in
+// the target.
+type ResiduePair struct {
+	Mobile, Target *Residue
+}
+
+// PairByNumber pairs residues with the same number and insertion code.
+func PairByNumber(mobile, target []*Residue) []ResiduePair {
+	byID := make(map[string]*Residue, len(target))
+	for _, r := range target {
+		if _, ok := byID[r.ID()]; !ok {
+			byID[r.ID()] = r
+		}
+	}
+
+	var pairs []ResiduePair
+	for _, r := range mobile {
+		if other, ok := byID[r.ID()]; ok {
+			pairs = append(pairs, ResiduePair{r, other})
+		}
+	}
+	return pairs
+}
+
+// Alignment scores. Gaps at the ends of either sequence are free, so that a
+// domain can be aligned to a full-length sequence.
+const (
+	alignMatch    = 2
+	alignMismatch = -1
+	alignGap      = -2
+)
+
+// AlignSequences aligns two sequences of one-letter codes globally and
+// returns the positions of the aligned letters, a in the first sequence and b
+// in the second, in order. X and N match nothing.
+func AlignSequences(a, b string) [][2]int {
+	n, m := len(a), len(b)
+	score := make([][]int, n+1)
+	for i := range score {
+		score[i] = make([]int, m+1)
+	}
+
+	substitution := func(i, j int) int {
+		if a[i] == b[j] && a[i] != 'X' && a[i] != 'N' {
+			return alignMatch
+		}
+		return alignMismatch
+	}
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			best := score[i-1][j-1] + substitution(i-1, j-1)
+			if s := score[i-1][j] + alignGap; s > best {
+				best = s
+			}
+			if s := score[i][j-1] + alignGap; s > best {
+				best = s
+			}
+			score[i][j] = best
+		}
+	}
+
+	// The alignment ends wherever the last row or column scores best; the
+	// rest of the other sequence is an end gap.
+	i, j := n, m
+	for k := 0; k <= m; k++ {
+		if score[n][k] > score[i][j] {
+			i, j = n, k
+		}
+	}
+	for k := 0; k <= n; k++ {
+		if score[k][m] > score[i][j] {
+			i, j = k, m
+		}
+	}
+
+	var pairs [][2]int
+	for i > 0 && j > 0 {
+		switch {
+		case score[i][j] == score[i-1][j-1]+substitution(i-1, j-1):
+			pairs = append(pairs, [2]int{i - 1, j - 1})
+			i, j = i-1, j-1
+		case score[i][j] == score[i-1][j]+alignGap:
+			i--
+		default:
+			j--
+		}
+	}
+	for l, r := 0, len(pairs)-1; l < r; l, r = l+1, r-1 {
+		pairs[l], pairs[r] = pairs[r], pairs[l]
+	}
+	return pairs
+}
+
+// PairAtoms returns the atoms of the given set that are present in both
+// residues of every pair, in the same order. Of alternate locations, the one
+// with the highest occupancy is used.
+func PairAtoms(pairs []ResiduePair, set AtomSet) (mobile, target []*Atom) {
+	for _, pair := range pairs {
+		var names []string
+		switch set {
+		case AtomsCA:
+			names = []string{"CA"}
+		case AtomsBackbone:
+			names = backboneAtoms
+		case AtomsHeavy:
+			if pair.Mobile.Name != pair.Target.Name {
+				names = backboneAtoms
+				break
+			}
+			seen := make(map[string]bool)
+			for _, a := range pair.Mobile.Atoms {
+				if a.Element != "H" && a.Element != "D" && !seen[a.Name] {
+					seen[a.Name] = true
+					names = append(names, a.Name)
+				}
+			}
+		}
+
+		for _, name := range names {
+			m, t := pair.Mobile.Atom(name), pair.Target.Atom(name)
+			if m != nil && t != nil {
+				mobile = append(mobile, m)
+				target = append(target, t)
+			}
+		}
+	}
+	return mobile, target
+}
@@ -0,0 +1,59 @@
+package structure
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_AlignSequences(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected [][2]int
+	}{
+		{a: "MKV", b: "MKV", expected: [][2]int{{0, 0}, {1, 1}, {2, 2}}},
+		// A domain aligned to the full sequence, with free end gaps.
+		{a: "GLAST", b: "MKVGLASTPHY", expected: [][2]int{{0, 3}, {1, 4}, {2, 5}, {3, 6}, {4, 7}}},
+		{a: "MKVGLASTPHY", b: "GLAST", expected: [][2]int{{3, 0}, {4, 1}, {5, 2}, {6, 3}, {7, 4}}},
+		// One residue missing in the middle.
+		{a: "MKVGLASTPHYW", b: "MKVGLSTPHYW", expected: [][2]int{
+			{0, 0}, {1, 1}, {2, 2}, {3, 3}, {4, 4}, {6, 5}, {7, 6}, {8, 7}, {9, 8}, {10, 9}, {11, 10},
+		}},
+		{a: "", b: "MKV"},
+	}
+
+	for _, tc := range testCases {
+		if got := AlignSequences(tc.a, tc.b); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("AlignSequences(%q, %q): expected %v, got %v", tc.a, tc.b, tc.expected, got)
+		}
+	}
+}
+
+func Test_PairAtoms(t *testing.T) {
+	s, err := ReadPDB(strings.NewReader(testPDB))
+	if err != nil {
+		t.Fatalf("ReadPDB() returned error: %v", err)
+	}
+	residues := s.PolymerResidues(s.Models[0].Chain("A"))
+	pairs := PairByNumber(residues, residues)
+	if len(pairs) != len(residues) {
+		t.Fatalf("PairByNumber(): expected %d pairs, got %d", len(residues), len(pairs))
+	}
+
+	for _, set := range []AtomSet{AtomsCA, AtomsBackbone, AtomsHeavy} {
+		mobile, target := PairAtoms(pairs, set)
+		if len(mobile) == 0 || len(mobile) != len(target) {
+			t.Errorf("PairAtoms(%s): expected paired atoms, got %d and %d", set, len(mobile), len(target))
+			continue
+		}
+		for i := range mobile {
+			if mobile[i] != target[i] || (set == AtomsCA && mobile[i].Name != "CA") {
+				t.Errorf("PairAtoms(%s): unexpected pair %+v, %+v", set, mobile[i], target[i])
+			}
+		}
+	}
+
+	if _, err := ParseAtomSet("sidechain"); err == nil {
+		t.Errorf("ParseAtomSet(): expected an error for an unknown atom set")
+	}
+}
@@ -0,0 +1,75 @@
+package structure
+
+// residueKey identifies a residue within a chain. The name is part of it so
+// that point mutations modeled as alternate locations stay separate.
+type residueKey struct {
+	seqNum int
+	iCode  string
+	name   string
+}
+
+// builder assembles a Structure from atom records in file order, creating
+// models, chains and residues as they are first seen.
+type builder struct {
+	structure *Structure
+	model     *Model
+	chains    map[string]*Chain
+	residues  map[*Chain]map[residueKey]*Residue
+}
+
+func newBuilder() *builder {
+	return &builder{structure: &Structure{}}
+}
+
+// startModel begins a new model. Atoms added before any model is started go
+// into model 1.
+func (b *builder) startModel(serial int) {
+	b.model = &Model{Serial: serial}
+	b.structure.Models = append(b.structure.Models, b.model)
+	b.chains = make(map[string]*Chain)
+	b.residues = make(map[*Chain]map[residueKey]*Residue)
+}
+
+func (b *builder) addAtom(chainID, resName string, seqNum int, iCode string, atom *Atom) {
+	if b.model == nil {
+		b.startModel(1)
+	}
+
+	chain, ok := b.chains[chainID]
+	if !ok {
+		chain = &Chain{ID: chainID}
+		b.chains[chainID] = chain
+		b.residues[chain] = make(map[residueKey]*Residue)
+		b.model.Chains = append(b.model.Chains, chain)
+	}
+
+	key := residueKey{seqNum, iCode, resName}
+	residue, ok := b.residues[chain][key]
+	if !ok {
+		residue = &Residue{Name: resName, SeqNum: seqNum, ICode: iCode, HetAtm: atom.HetAtm}
+		b.residues[chain][key] = residue
+		chain.Residues = append(chain.Residues, residue)
+	}
+	residue.Atoms = append(residue.Atoms, atom)
+}
+
+// addSeqRes appends a residue to the full sequence of a chain.
+func (b *builder) addSeqRes(chainID, resName string) {
+	seq := b.structure.Sequence(chainID)
+	if seq == nil {
+		seq = &Sequence{ChainID: chainID}
+		b.structure.Sequences = append(b.structure.Sequences, seq)
+	}
+	seq.Residues = append(seq.Residues, resName)
+}
+
+// addModifiedResidue records the standard parent of a modified residue.
+func (b *builder) addModifiedResidue(resName, parent string) {
+	if resName == "" || parent == "" {
+		return
+	}
+	if b.structure.ModifiedResidues == nil {
+		b.structure.ModifiedResidues = make(map[string]string)
+	}
+	b.structure.ModifiedResidues[resName] = parent
+}
@@ -0,0 +1,529 @@
+package structure
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type cifTokenKind int
+
+const (
+	cifEOF cifTokenKind = iota
+	cifData
+	cifLoop
+	cifTag
+	cifValue
+)
+
+// cifToken is a token of a CIF file. Values are unquoted; the special values
+// . (inapplicable) and ? (unknown) are marked as null.
+type cifToken struct {
+	kind cifTokenKind
+	text string
+	null bool
+	line int
+}
+
+// cifTokenizer splits a CIF file into tokens one line at a time, so files of
+// any size can be read with constant memory.
+type cifTokenizer struct {
+	scanner *bufio.Scanner
+	line    string
+	lineNum int
+	pos     int
+	unread  *cifToken
+}
+
+func newCIFTokenizer(r io.Reader) *cifTokenizer {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &cifTokenizer{scanner: scanner}
+}
+
+// readLine advances to the next line and reports whether there is one.
+func (t *cifTokenizer) readLine() (bool, error) {
+	if !t.scanner.Scan() {
+		return false, t.scanner.Err()
+	}
+	t.line = strings.TrimRight(t.scanner.Text(), "\r")
+	t.lineNum++
+	t.pos = 0
+	return true, nil
+}
+
+// push returns tok from the next call to next.
+func (t *cifTokenizer) push(tok cifToken) {
+	t.unread = &tok
+}
+
+func (t *cifTokenizer) next() (cifToken, error) {
+	if t.unread != nil {
+		tok := *t.unread
+		t.unread = nil
+		return tok, nil
+	}
+
+	for {
+		for t.pos < len(t.line) && isCIFSpace(t.line[t.pos]) {
+			t.pos++
+		}
+		if t.pos < len(t.line) && t.line[t.pos] != '#' {
+			break
+		}
+
+		ok, err := t.readLine()
+		if err != nil {
+			return cifToken{}, err
+		}
+		if !ok {
+			return cifToken{kind: cifEOF, line: t.lineNum}, nil
+		}
+		if strings.HasPrefix(t.line, ";") {
+			return t.textField()
+		}
+	}
+
+	start := t.pos
+	switch quote := t.line[start]; quote {
+	case '\'', '"':
+		// A quote only ends the value if whitespace or the end of the line
+		// follows it.
+		for end := start + 1; end < len(t.line); end++ {
+			if t.line[end] == quote && (end+1 == len(t.line) || isCIFSpace(t.line[end+1])) {
+				t.pos = end + 1
+				return cifToken{kind: cifValue, text: t.line[start+1 : end], line: t.lineNum}, nil
+			}
+		}
+		return cifToken{}, &ParseError{Line: t.lineNum, Err: fmt.Errorf("unterminated quoted value")}
+	}
+
+	for t.pos < len(t.line) && !isCIFSpace(t.line[t.pos]) {
+		t.pos++
+	}
+	word := t.line[start:t.pos]
+	tok := cifToken{kind: cifValue, text: word, line: t.lineNum}
+	lower := strings.ToLower(word)
+	switch {
+	case strings.HasPrefix(word, "_"):
+		tok.kind = cifTag
+	case lower == "loop_":
+		tok.kind = cifLoop
+	case strings.HasPrefix(lower, "data_"):
+		tok.kind = cifData
+		tok.text = word[len("data_"):]
+	case word == "." || word == "?":
+		tok.null = true
+	}
+	return tok, nil
+}
+
+// textField reads a multi-line value delimited by lines starting with a
+// semicolon. The current line is the opening one.
+func (t *cifTokenizer) textField() (cifToken, error) {
+	first := t.lineNum
+	lines := []string{t.line[1:]}
+	for {
+		ok, err := t.readLine()
+		if err != nil {
+			return cifToken{}, err
+		}
+		if !ok {
+			return cifToken{}, &ParseError{Line: first, Err: fmt.Errorf("unterminated text field")}
+		}
+		if strings.HasPrefix(t.line, ";") {
+			t.pos = 1
+			text := strings.TrimSpace(strings.Join(lines, "\n"))
+			return cifToken{kind: cifValue, text: text, line: first}, nil
+		}
+		lines = append(lines, t.line)
+	}
+}
+
+func isCIFSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// ReadCIFFile reads a PDBx/mmCIF file.
+func ReadCIFFile(filename string) (*Structure, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	s, err := ReadCIF(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return s, nil
+}
+
+// ReadCIF reads a structure in PDBx/mmCIF format from the first data block.
+// Atoms come from _atom_site, using the author's chain IDs, residue numbers
+// and names as in PDB files. Full sequences come from _pdbx_poly_seq_scheme
+// and modified residues from _pdbx_struct_mod_residue. Only the entry ID,
+// title, experimental method and resolution are read from the other
+// categories.
+func ReadCIF(r io.Reader) (*Structure, error) {
+	t := newCIFTokenizer(r)
+	b := newBuilder()
+	blocks := 0
+
+	// Categories with a single row are written as items instead of loops.
+	// Those read in full are collected and added at the end of the block.
+	var singleTags []string
+	var singleRow []cifToken
+	finish := func() (*Structure, error) {
+		categories := make(map[string]bool)
+		for _, tag := range singleTags {
+			category, _, _ := strings.Cut(tag, ".")
+			if categories[category] {
+				continue
+			}
+			categories[category] = true
+
+			var tags []string
+			var row []cifToken
+			for i, other := range singleTags {
+				if strings.HasPrefix(other, category+".") {
+					tags = append(tags, other)
+					row = append(row, singleRow[i])
+				}
+			}
+			rows, err := newCIFRows(tags)
+			if err != nil {
+				return nil, err
+			}
+			if err := rows.add(b, row); err != nil {
+				return nil, err
+			}
+		}
+		return b.structure, nil
+	}
+
+	for {
+		tok, err := t.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.kind {
+		case cifEOF:
+			return finish()
+		case cifData:
+			blocks++
+			if blocks > 1 {
+				return finish()
+			}
+			b.structure.ID = tok.text
+		case cifLoop:
+			if err := readCIFLoop(t, b); err != nil {
+				return nil, err
+			}
+		case cifTag:
+			value, err := t.next()
+			if err != nil {
+				return nil, err
+			}
+			if value.kind != cifValue {
+				return nil, &ParseError{Line: value.line, Err: fmt.Errorf("missing value for %s", tok.text)}
+			}
+			tag := strings.ToLower(tok.text)
+			if isCIFRowsCategory(tag) {
+				singleTags = append(singleTags, tag)
+				singleRow = append(singleRow, value)
+			} else if !value.null {
+				setCIFItem(b.structure, tag, value.text)
+			}
+		default:
+			return nil, &ParseError{Line: tok.line, Err: fmt.Errorf("unexpected value %q", tok.text)}
+		}
+	}
+}
+
+// setCIFItem stores the metadata items that are part of a Structure.
+func setCIFItem(s *Structure, tag, value string) {
+	switch strings.ToLower(tag) {
+	case "_entry.id":
+		s.ID = value
+	case "_struct.title":
+		s.Title = strings.Join(strings.Fields(value), " ")
+	case "_exptl.method":
+		if s.Method == "" {
+			s.Method = value
+		}
+	case "_refine.ls_d_res_high", "_em_3d_reconstruction.resolution":
+		if resolution, err := strconv.ParseFloat(value, 64); err == nil && s.Resolution == 0 {
+			s.Resolution = resolution
+		}
+	}
+}
+
+// readCIFLoop reads a loop after its loop_ token. Rows of the categories read
+// in full are added to b as they are read; of other loops, only the first row
+// is kept.
+func readCIFLoop(t *cifTokenizer, b *builder) error {
+	var tags []string
+	for {
+		tok, err := t.next()
+		if err != nil {
+			return err
+		}
+		if tok.kind != cifTag {
+			t.push(tok)
+			break
+		}
+		tags = append(tags, strings.ToLower(tok.text))
+	}
+	if len(tags) == 0 {
+		return &ParseError{Line: t.lineNum, Err: fmt.Errorf("loop without tags")}
+	}
+
+	var rows cifRows
+	if isCIFRowsCategory(tags[0]) {
+		var err error
+		if rows, err = newCIFRows(tags); err != nil {
+			return &ParseError{Line: t.lineNum, Err: err}
+		}
+	}
+
+	row := make([]cifToken, 0, len(tags))
+	read := 0
+	for {
+		tok, err := t.next()
+		if err != nil {
+			return err
+		}
+		if tok.kind != cifValue {
+			t.push(tok)
+			break
+		}
+
+		row = append(row, tok)
+		if len(row) < len(tags) {
+			continue
+		}
+		if rows != nil {
+			if err := rows.add(b, row); err != nil {
+				return &ParseError{Line: tok.line, Err: err}
+			}
+		} else if read == 0 {
+			for i, value := range row {
+				if !value.null {
+					setCIFItem(b.structure, tags[i], value.text)
+				}
+			}
+		}
+		row = row[:0]
+		read++
+	}
+	if len(row) != 0 {
+		return &ParseError{Line: t.lineNum, Err: fmt.Errorf("loop has %d values left over for %d tags", len(row), len(tags))}
+	}
+	return nil
+}
+
+// cifRows receives the rows of a category that is read in full.
+type cifRows interface {
+	add(b *builder, row []cifToken) error
+}
+
+// cifRowsCategories are the categories read in full.
+var cifRowsCategories = []string{"_atom_site.", "_pdbx_poly_seq_scheme.", "_pdbx_struct_mod_residue."}
+
+func isCIFRowsCategory(tag string) bool {
+	for _, category := range cifRowsCategories {
+		if strings.HasPrefix(tag, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// newCIFRows returns the reader for the rows of a category read in full,
+// given the lower-case tags of its items.
+func newCIFRows(tags []string) (cifRows, error) {
+	switch {
+	case strings.HasPrefix(tags[0], "_atom_site."):
+		return newAtomSiteColumns(tags)
+	case strings.HasPrefix(tags[0], "_pdbx_poly_seq_scheme."):
+		return &polySeqColumns{
+			monID:    cifColumn(tags, "_pdbx_poly_seq_scheme.mon_id"),
+			seqID:    cifColumn(tags, "_pdbx_poly_seq_scheme.seq_id"),
+			asymID:   cifColumn(tags, "_pdbx_poly_seq_scheme.asym_id"),
+			strandID: cifColumn(tags, "_pdbx_poly_seq_scheme.pdb_strand_id"),
+			last:     make(map[string]string),
+		}, nil
+	default:
+		return &modResidueColumns{
+			compID:     cifColumn(tags, "_pdbx_struct_mod_residue.label_comp_id"),
+			authCompID: cifColumn(tags, "_pdbx_struct_mod_residue.auth_comp_id"),
+			parentID:   cifColumn(tags, "_pdbx_struct_mod_residue.parent_comp_id"),
+		}, nil
+	}
+}
+
+// cifColumn returns the position of a tag in a loop, or -1.
+func cifColumn(tags []string, tag string) int {
+	tag = strings.ToLower(tag)
+	for i, t := range tags {
+		if t == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// cifValueOf returns the first of the given columns that is present and not
+// null in the row.
+func cifValueOf(row []cifToken, columns ...int) string {
+	for _, i := range columns {
+		if i >= 0 && !row[i].null {
+			return row[i].text
+		}
+	}
+	return ""
+}
+
+// polySeqColumns reads _pdbx_poly_seq_scheme. Of residues with several
+// alternatives at one position, only the first is kept.
+type polySeqColumns struct {
+	monID, seqID, asymID, strandID int
+	last                           map[string]string
+}
+
+func (c *polySeqColumns) add(b *builder, row []cifToken) error {
+	chainID := cifValueOf(row, c.strandID, c.asymID)
+	seqID := cifValueOf(row, c.seqID)
+	if last, ok := c.last[chainID]; ok && seqID != "" && last == seqID {
+		return nil
+	}
+	c.last[chainID] = seqID
+	b.addSeqRes(chainID, cifValueOf(row, c.monID))
+	return nil
+}
+
+// modResidueColumns reads _pdbx_struct_mod_residue.
+type modResidueColumns struct {
+	compID, authCompID, parentID int
+}
+
+func (c *modResidueColumns) add(b *builder, row []cifToken) error {
+	b.addModifiedResidue(cifValueOf(row, c.authCompID, c.compID), cifValueOf(row, c.parentID))
+	return nil
+}
+
+// atomSiteColumns holds the positions of the _atom_site items in a loop,
+// -1 for those that are missing.
+type atomSiteColumns struct {
+	group, id, element                  int
+	atomName, altID, compID, asymID     int
+	seqID, insCode                      int
+	x, y, z, occupancy, bFactor, charge int
+	authSeqID, authCompID, authAsymID   int
+	authAtomName, model                 int
+}
+
+func newAtomSiteColumns(tags []string) (*atomSiteColumns, error) {
+	index := func(item string) int {
+		return cifColumn(tags, "_atom_site."+item)
+	}
+
+	c := &atomSiteColumns{
+		group: index("group_PDB"), id: index("id"), element: index("type_symbol"),
+		atomName: index("label_atom_id"), altID: index("label_alt_id"), compID: index("label_comp_id"),
+		asymID: index("label_asym_id"), seqID: index("label_seq_id"), insCode: index("pdbx_PDB_ins_code"),
+		x: index("Cartn_x"), y: index("Cartn_y"), z: index("Cartn_z"),
+		occupancy: index("occupancy"), bFactor: index("B_iso_or_equiv"), charge: index("pdbx_formal_charge"),
+		authSeqID: index("auth_seq_id"), authCompID: index("auth_comp_id"), authAsymID: index("auth_asym_id"),
+		authAtomName: index("auth_atom_id"), model: index("pdbx_PDB_model_num"),
+	}
+	if c.x < 0 || c.y < 0 || c.z < 0 {
+		return nil, fmt.Errorf("_atom_site has no Cartesian coordinates")
+	}
+	return c, nil
+}
+
+func (c *atomSiteColumns) add(b *builder, row []cifToken) error {
+	value := func(columns ...int) string {
+		return cifValueOf(row, columns...)
+	}
+
+	atom := &Atom{
+		Name:      value(c.authAtomName, c.atomName),
+		AltLoc:    value(c.altID),
+		Occupancy: 1,
+		Element:   strings.ToUpper(value(c.element)),
+		Charge:    pdbCharge(value(c.charge)),
+		HetAtm:    value(c.group) == "HETATM",
+	}
+	atom.Serial, _ = strconv.Atoi(value(c.id))
+
+	var err error
+	for i, coordinate := range []*float64{&atom.X, &atom.Y, &atom.Z} {
+		if *coordinate, err = strconv.ParseFloat(value([]int{c.x, c.y, c.z}[i]), 64); err != nil {
+			return fmt.Errorf("invalid coordinate: %w", err)
+		}
+	}
+	if v := value(c.occupancy); v != "" {
+		if atom.Occupancy, err = strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("invalid occupancy: %w", err)
+		}
+	}
+	if v := value(c.bFactor); v != "" {
+		if atom.BFactor, err = strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("invalid B-factor: %w", err)
+		}
+	}
+
+	seqNum := 0
+	if v := value(c.authSeqID, c.seqID); v != "" {
+		if seqNum, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("invalid residue number: %w", err)
+		}
+	}
+
+	model := 1
+	if v := value(c.model); v != "" {
+		if model, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("invalid model number: %w", err)
+		}
+	}
+	if b.model == nil || b.model.Serial != model {
+		b.startModel(model)
+	}
+
+	b.addAtom(value(c.authAsymID, c.asymID), value(c.authCompID, c.compID), seqNum, value(c.insCode), atom)
+	return nil
+}
+
+// pdbCharge converts a formal charge such as -1 to PDB notation, 1-.
+func pdbCharge(charge string) string {
+	n, err := strconv.Atoi(charge)
+	switch {
+	case err != nil, n == 0:
+		return ""
+	case n < 0:
+		return strconv.Itoa(-n) + "-"
+	default:
+		return strconv.Itoa(n) + "+"
+	}
+}
+
+// cifCharge converts a PDB charge such as 1- to a formal charge, -1.
+func cifCharge(charge string) string {
+	if len(charge) != 2 {
+		return ""
+	}
+	switch charge[1] {
+	case '-':
+		return "-" + charge[:1]
+	case '+':
+		return charge[:1]
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package structure
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testCIF = `data_1ABC
+#
+_entry.id   1ABC
+#
+_struct.title
+;THE CRYSTAL STRUCTURE
+ OF A TEST PROTEIN
+;
+_exptl.method 'X-RAY DIFFRACTION'
+_refine.ls_d_res_high 1.74
+#
+loop_
+_atom_site.group_PDB
+_atom_site.id
+_atom_site.type_symbol
+_atom_site.label_atom_id
+_atom_site.label_alt_id
+_atom_site.label_comp_id
+_atom_site.label_asym_id
+_atom_site.label_seq_id
+_atom_site.pdbx_PDB_ins_code
+_atom_site.Cartn_x
+_atom_site.Cartn_y
+_atom_site.Cartn_z
+_atom_site.occupancy
+_atom_site.B_iso_or_equiv
+_atom_site.pdbx_formal_charge
+_atom_site.auth_seq_id
+_atom_site.auth_asym_id
+_atom_site.pdbx_PDB_model_num
+ATOM   1 N  N   . VAL A 1 ? 6.204 16.869 4.854 1.00 49.05 ? 1   A 1
+ATOM   2 C  CA  . VAL A 1 ? 6.913 17.759 4.607 1.00 43.14 ? 1   A 1
+ATOM   3 C  CA  A GLY A 2 ? 8.100 18.000 5.000 0.60 20.00 ? 2   A 1
+ATOM   4 C  CA  B GLY A 2 ? 8.200 18.100 5.100 0.40 21.00 ? 2   A 1
+HETATM 5 Fe FE  . HEM C . ? 11.000 21.000 8.000 1.00 15.00 ? 201 A 1
+HETATM 6 O  O   . HOH D . ? 12.000 22.000 9.000 0.50 35.00 -1 301 A 1
+ATOM   7 N  N   . VAL A 1 ? 7.204 16.869 4.854 1.00 49.05 ? 1   A 2
+#
+loop_
+_pdbx_audit_revision_history.ordinal
+_pdbx_audit_revision_history.revision_date
+1 1984-03-07
+2 2011-07-13
+#
+data_second
+_entry.id 2DEF
+`
+
+func Test_ReadCIF(t *testing.T) {
+	s, err := ReadCIF(strings.NewReader(testCIF))
+	if err != nil {
+		t.Fatalf("ReadCIF() returned error: %v", err)
+	}
+
+	if s.ID != "1ABC" || s.Title != "THE CRYSTAL STRUCTURE OF A TEST PROTEIN" {
+		t.Errorf("Unexpected header: %q, %q", s.ID, s.Title)
+	}
+	if s.Method != "X-RAY DIFFRACTION" || s.Resolution != 1.74 {
+		t.Errorf("Unexpected method and resolution: %q, %v", s.Method, s.Resolution)
+	}
+	if len(s.Models) != 2 || s.Models[1].Serial != 2 {
+		t.Fatalf("Expected 2 models, got %d", len(s.Models))
+	}
+
+	chainA := s.Models[0].Chain("A")
+	if chainA == nil || len(s.Models[0].Chains) != 1 {
+		t.Fatalf("Expected only author chain A, got %d chains", len(s.Models[0].Chains))
+	}
+	var residues []string
+	for _, r := range chainA.Residues {
+		residues = append(residues, r.Name+r.ID())
+	}
+	if got := strings.Join(residues, " "); got != "VAL1 GLY2 HEM201 HOH301" {
+		t.Errorf("Unexpected residues in chain A: %s", got)
+	}
+
+	if ca := chainA.Residues[1].Atom("CA"); ca.AltLoc != "A" || ca.Occupancy != 0.6 {
+		t.Errorf("Expected the altloc with the highest occupancy, got %+v", ca)
+	}
+	fe := chainA.Residues[2].Atoms[0]
+	if !fe.HetAtm || fe.Element != "FE" || fe.Serial != 5 || fe.X != 11 {
+		t.Errorf("Unexpected heme atom: %+v", fe)
+	}
+	if water := chainA.Residues[3].Atoms[0]; water.Charge != "1-" {
+		t.Errorf("Expected charge 1-, got %+v", water)
+	}
+	if a := s.Models[1].Atoms()[0]; a.X != 7.204 {
+		t.Errorf("Expected the coordinates of model 2, got %+v", a)
+	}
+}
+
+func Test_cifTokenizer(t *testing.T) {
+	input := "data_x _a.b 'it''s quoted' \"two words\" . ? loop_\n;text\nfield\n; after # comment\n"
+	var got []string
+	tokenizer := newCIFTokenizer(strings.NewReader(input))
+	for {
+		tok, err := tokenizer.next()
+		if err != nil {
+			t.Fatalf("next() returned error: %v", err)
+		}
+		if tok.kind == cifEOF {
+			break
+		}
+		text := tok.text
+		if tok.null {
+			text = "<null>"
+		}
+		got = append(got, text)
+	}
+
+	expected := []string{"x", "_a.b", "it''s quoted", "two words", "<null>", "<null>", "loop_", "text\nfield", "after"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected tokens %q, got %q", expected, got)
+	}
+}
+
+func Test_ReadCIF_errors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		line  int
+	}{
+		{
+			name:  "Unterminated quote",
+			input: "data_x\n_struct.title 'open\n",
+			line:  2,
+		},
+		{
+			name:  "Unterminated text field",
+			input: "data_x\n_struct.title\n;open\n",
+			line:  3,
+		},
+		{
+			name:  "Invalid coordinate",
+			input: "data_x\nloop_\n_atom_site.Cartn_x\n_atom_site.Cartn_y\n_atom_site.Cartn_z\n1.0 x 3.0\n",
+			line:  6,
+		},
+		{
+			name:  "Incomplete row",
+			input: "data_x\nloop_\n_atom_site.Cartn_x\n_atom_site.Cartn_y\n_atom_site.Cartn_z\n1.0 2.0 3.0\n1.0\n",
+			line:  7,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ReadCIF(strings.NewReader(tc.input))
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Line != tc.line {
+				t.Errorf("Expected parse error on line %d, got %v", tc.line, err)
+			}
+		})
+	}
+}
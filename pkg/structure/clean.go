@@ -0,0 +1,233 @@
+package structure
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AltLocHighest selects, per residue, the alternate location with the
+// highest total occupancy.
+const AltLocHighest = "highest"
+
+// waterNames are the residue names used for water molecules.
+var waterNames = map[string]bool{"HOH": true, "WAT": true, "DOD": true, "H2O": true}
+
+// IsWater reports whether r is a water molecule.
+func (r *Residue) IsWater() bool {
+	return waterNames[r.Name]
+}
+
+// CleanOptions selects the cleanup steps done by Clean. The zero value
+// changes nothing.
+type CleanOptions struct {
+	// RemoveWaters removes water molecules.
+	RemoveWaters bool
+	// AltLoc keeps a single alternate location: AltLocHighest, or an
+	// indicator such as A. Residues without that indicator keep the one with
+	// the highest occupancy, and atoms without an alternate location are
+	// always kept. Empty keeps all of them.
+	AltLoc string
+	// RemoveHetero lists the names of HETATM residues to remove. The name
+	// all removes every HETATM residue, including modified residues in
+	// polymers.
+	RemoveHetero []string
+	// Chains lists the chains to keep. Empty keeps all of them.
+	Chains []string
+	// Renumber numbers the residues of each chain from 1 in order and
+	// clears insertion codes.
+	Renumber bool
+}
+
+// CleanSummary counts what Clean changed.
+type CleanSummary struct {
+	Waters      int
+	AltLocAtoms int
+	// Hetero counts removed HETATM residues by name.
+	Hetero     map[string]int
+	Chains     []string
+	Renumbered int
+}
+
+func (s CleanSummary) String() string {
+	var parts []string
+	if s.Waters > 0 {
+		parts = append(parts, fmt.Sprintf("removed %d waters", s.Waters))
+	}
+	if s.AltLocAtoms > 0 {
+		parts = append(parts, fmt.Sprintf("removed %d alternate location atoms", s.AltLocAtoms))
+	}
+	if len(s.Hetero) > 0 {
+		names := make([]string, 0, len(s.Hetero))
+		for name := range s.Hetero {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		counts := make([]string, len(names))
+		for i, name := range names {
+			counts[i] = fmt.Sprintf("%d %s", s.Hetero[name], name)
+		}
+		parts = append(parts, fmt.Sprintf("removed heteroatom residues %s", strings.Join(counts, ", ")))
+	}
+	if len(s.Chains) > 0 {
+		parts = append(parts, fmt.Sprintf("removed chains %s", strings.Join(s.Chains, ", ")))
+	}
+	if s.Renumbered > 0 {
+		parts = append(parts, fmt.Sprintf("renumbered %d residues", s.Renumbered))
+	}
+	if len(parts) == 0 {
+		return "nothing to clean"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Clean applies the cleanup steps selected by opts to every model of s in
+// place. Residues left without atoms and chains left without residues are
+// removed.
+func Clean(s *Structure, opts CleanOptions) CleanSummary {
+	summary := CleanSummary{Hetero: make(map[string]int)}
+	keepChains := stringSet(opts.Chains)
+	removeHetero := stringSet(opts.RemoveHetero)
+	removedChains := make(map[string]bool)
+
+	for _, m := range s.Models {
+		var chains []*Chain
+		for _, c := range m.Chains {
+			if len(keepChains) > 0 && !keepChains[c.ID] {
+				removedChains[c.ID] = true
+				continue
+			}
+			if opts.AltLoc != "" {
+				summary.AltLocAtoms += selectAltLoc(c, opts.AltLoc)
+			}
+
+			var residues []*Residue
+			for _, r := range c.Residues {
+				switch {
+				case len(r.Atoms) == 0:
+				case r.IsWater():
+					if opts.RemoveWaters {
+						summary.Waters++
+					} else {
+						residues = append(residues, r)
+					}
+				case r.HetAtm && (removeHetero["all"] || removeHetero[r.Name]):
+					summary.Hetero[r.Name]++
+				default:
+					residues = append(residues, r)
+				}
+			}
+			if len(residues) == 0 {
+				continue
+			}
+			c.Residues = residues
+
+			if opts.Renumber {
+				for i, r := range c.Residues {
+					if r.SeqNum != i+1 || r.ICode != "" {
+						summary.Renumbered++
+					}
+					r.SeqNum, r.ICode = i+1, ""
+				}
+			}
+			chains = append(chains, c)
+		}
+		m.Chains = chains
+	}
+
+	if len(keepChains) > 0 {
+		var sequences []*Sequence
+		for _, seq := range s.Sequences {
+			if keepChains[seq.ChainID] {
+				sequences = append(sequences, seq)
+			}
+		}
+		s.Sequences = sequences
+	}
+
+	for id := range removedChains {
+		summary.Chains = append(summary.Chains, id)
+	}
+	sort.Strings(summary.Chains)
+	if len(summary.Hetero) == 0 {
+		summary.Hetero = nil
+	}
+	return summary
+}
+
+// selectAltLoc keeps a single alternate location in the residues of c and
+// returns the number of atoms removed. Residues sharing a number, such as
+// point mutations modeled as alternate locations, are treated as one, so
+// only one of them keeps its atoms.
+func selectAltLoc(c *Chain, altLoc string) int {
+	groups := make(map[string][]*Residue)
+	for _, r := range c.Residues {
+		groups[r.ID()] = append(groups[r.ID()], r)
+	}
+
+	removed := 0
+	for _, group := range groups {
+		keep := altLoc
+		if keep == AltLocHighest || !hasAltLoc(group, keep) {
+			keep = highestAltLoc(group)
+		}
+		for _, r := range group {
+			var atoms []*Atom
+			for _, a := range r.Atoms {
+				if a.AltLoc == "" || a.AltLoc == keep {
+					a.AltLoc = ""
+					atoms = append(atoms, a)
+				} else {
+					removed++
+				}
+			}
+			r.Atoms = atoms
+		}
+	}
+	return removed
+}
+
+func hasAltLoc(residues []*Residue, altLoc string) bool {
+	for _, r := range residues {
+		for _, a := range r.Atoms {
+			if a.AltLoc == altLoc {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// highestAltLoc returns the alternate location of the residues with the
+// highest total occupancy, the first one on ties.
+func highestAltLoc(residues []*Residue) string {
+	var order []string
+	occupancy := make(map[string]float64)
+	for _, r := range residues {
+		for _, a := range r.Atoms {
+			if a.AltLoc == "" {
+				continue
+			}
+			if _, ok := occupancy[a.AltLoc]; !ok {
+				order = append(order, a.AltLoc)
+			}
+			occupancy[a.AltLoc] += a.Occupancy
+		}
+	}
+
+	best := ""
+	for _, altLoc := range order {
+		if best == "" || occupancy[altLoc] > occupancy[best] {
+			best = altLoc
+		}
+	}
+	return best
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
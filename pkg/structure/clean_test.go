@@ -0,0 +1,106 @@
+package structure
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCleanPDB = `ATOM      1  N   VAL A   1       1.000   1.000   1.000  1.00 10.00           N
+ATOM      2  CA AVAL A   1       2.000   1.000   1.000  0.40 10.00           C
+ATOM      3  CA BVAL A   1       2.100   1.000   1.000  0.60 10.00           C
+ATOM      4  CA AGLY A   2       3.000   1.000   1.000  0.70 10.00           C
+ATOM      5  CA BALA A   2       3.100   1.000   1.000  0.30 10.00           C
+ATOM      6  CA  SER A   2A      4.000   1.000   1.000  1.00 10.00           C
+HETATM    7  S   SO4 A 101       5.000   1.000   1.000  1.00 10.00           S
+HETATM    8  C1  GOL A 102       6.000   1.000   1.000  1.00 10.00           C
+HETATM    9  O   HOH A 201       7.000   1.000   1.000  1.00 10.00           O
+ATOM     10  CA  LYS B   1       8.000   1.000   1.000  1.00 10.00           C
+HETATM   11  O   HOH B 202       9.000   1.000   1.000  1.00 10.00           O
+`
+
+func residueNames(c *Chain) string {
+	var names []string
+	for _, r := range c.Residues {
+		var altLocs []string
+		for _, a := range r.Atoms {
+			altLocs = append(altLocs, a.AltLoc)
+		}
+		names = append(names, r.Name+r.ID()+strings.Join(altLocs, ""))
+	}
+	return strings.Join(names, " ")
+}
+
+func Test_Clean(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     CleanOptions
+		chains   []string
+		summary  CleanSummary
+		expected string
+	}{
+		{
+			name:     "Nothing",
+			opts:     CleanOptions{},
+			chains:   []string{"A", "B"},
+			expected: "VAL1AB GLY2A ALA2B SER2A SO4101 GOL102 HOH201",
+		},
+		{
+			name:     "Waters and highest altloc",
+			opts:     CleanOptions{RemoveWaters: true, AltLoc: AltLocHighest},
+			chains:   []string{"A", "B"},
+			summary:  CleanSummary{Waters: 2, AltLocAtoms: 2},
+			expected: "VAL1 GLY2 SER2A SO4101 GOL102",
+		},
+		{
+			name:     "Missing altloc falls back to highest",
+			opts:     CleanOptions{AltLoc: "B", RemoveHetero: []string{"SO4"}, Chains: []string{"A"}},
+			chains:   []string{"A"},
+			summary:  CleanSummary{AltLocAtoms: 2, Hetero: map[string]int{"SO4": 1}, Chains: []string{"B"}},
+			expected: "VAL1 ALA2 SER2A GOL102 HOH201",
+		},
+		{
+			name:     "All heteroatoms and renumbering",
+			opts:     CleanOptions{RemoveWaters: true, AltLoc: "A", RemoveHetero: []string{"all"}, Renumber: true},
+			chains:   []string{"A", "B"},
+			summary:  CleanSummary{Waters: 2, AltLocAtoms: 2, Hetero: map[string]int{"SO4": 1, "GOL": 1}, Renumbered: 1},
+			expected: "VAL1 GLY2 SER3",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := ReadPDB(strings.NewReader(testCleanPDB))
+			if err != nil {
+				t.Fatalf("ReadPDB() returned error: %v", err)
+			}
+
+			summary := Clean(s, tc.opts)
+			if !reflect.DeepEqual(summary, tc.summary) {
+				t.Errorf("Expected summary %+v, got %+v", tc.summary, summary)
+			}
+
+			var chains []string
+			for _, c := range s.Models[0].Chains {
+				chains = append(chains, c.ID)
+			}
+			if !reflect.DeepEqual(chains, tc.chains) {
+				t.Fatalf("Expected chains %v, got %v", tc.chains, chains)
+			}
+			if got := residueNames(s.Models[0].Chain("A")); got != tc.expected {
+				t.Errorf("Expected residues %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func Test_CleanSummary_String(t *testing.T) {
+	summary := CleanSummary{Waters: 3, Hetero: map[string]int{"SO4": 2, "GOL": 1}, Chains: []string{"B", "C"}}
+	expected := "removed 3 waters; removed heteroatom residues 1 GOL, 2 SO4; removed chains B, C"
+	if got := summary.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if got := (CleanSummary{}).String(); got != "nothing to clean" {
+		t.Errorf("Expected nothing to clean, got %q", got)
+	}
+}
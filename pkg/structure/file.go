@@ -0,0 +1,118 @@
+package structure
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Format is a structure file format.
+type Format string
+
+const (
+	FormatPDB Format = "pdb"
+	FormatCIF Format = "cif"
+)
+
+// ParseFormat parses a format name as used on the command line. mmcif is
+// accepted for cif.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "pdb", "ent":
+		return FormatPDB, nil
+	case "cif", "mmcif":
+		return FormatCIF, nil
+	}
+	return "", fmt.Errorf("unknown structure format %q, expected pdb or cif", name)
+}
+
+// Extension returns the file name extension of the format, with the dot.
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ReadFile reads a structure file in either format, gzipped or not. The
+// format is detected from the content: mmCIF files start with a data block.
+func ReadFile(filename string) (*Structure, Format, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	s, format, err := Read(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", filename, err)
+	}
+	return s, format, nil
+}
+
+// Read reads a structure in either format, gzipped or not, and reports the
+// format it was in.
+func Read(r io.Reader) (*Structure, Format, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, "", err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	format, err := detectFormat(br)
+	if err != nil {
+		return nil, "", err
+	}
+	var s *Structure
+	if format == FormatCIF {
+		s, err = ReadCIF(br)
+	} else {
+		s, err = ReadPDB(br)
+	}
+	return s, format, err
+}
+
+// detectFormat looks at the first line that is neither blank nor a comment
+// without consuming it. Files with nothing but comments in the buffered
+// head are taken as PDB.
+func detectFormat(br *bufio.Reader) (Format, error) {
+	head, err := br.Peek(br.Size())
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	lines := strings.Split(string(head), "\n")
+	if err == nil {
+		// The last line may be cut off.
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(line), "data_") {
+			return FormatCIF, nil
+		}
+		break
+	}
+	return FormatPDB, nil
+}
+
+// Write writes s in the given format.
+func Write(w io.Writer, s *Structure, format Format) error {
+	switch format {
+	case FormatPDB:
+		return WritePDB(w, s)
+	case FormatCIF:
+		return WriteCIF(w, s)
+	}
+	return fmt.Errorf("unknown structure format %q", format)
+}
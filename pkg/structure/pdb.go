@@ -0,0 +1,187 @@
+package structure
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseError reports a malformed line in a structure file.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ReadPDBFile reads a legacy PDB file.
+func ReadPDBFile(filename string) (*Structure, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	s, err := ReadPDB(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return s, nil
+}
+
+// ReadPDB reads a structure in legacy PDB format. Records other than HEADER,
+// TITLE, EXPDTA, REMARK 2 (resolution), SEQRES, MODRES, MODEL, ATOM and
+// HETATM are ignored.
+func ReadPDB(r io.Reader) (*Structure, error) {
+	b := newBuilder()
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		record := strings.TrimSpace(column(text, 1, 6))
+
+		var err error
+		switch record {
+		case "HEADER":
+			b.structure.ID = strings.TrimSpace(column(text, 63, 66))
+		case "TITLE":
+			title := strings.TrimSpace(column(text, 11, 80))
+			if b.structure.Title != "" {
+				title = " " + title
+			}
+			b.structure.Title += title
+		case "EXPDTA":
+			b.structure.Method = strings.TrimSpace(column(text, 11, 79))
+		case "REMARK":
+			// REMARK   2 RESOLUTION.    1.74 ANGSTROMS.
+			if fields := strings.Fields(column(text, 7, 80)); len(fields) >= 3 && fields[0] == "2" && fields[1] == "RESOLUTION." {
+				b.structure.Resolution, _ = strconv.ParseFloat(fields[2], 64)
+			}
+		case "SEQRES":
+			chainID := strings.TrimSpace(column(text, 12, 12))
+			for _, name := range strings.Fields(column(text, 20, 70)) {
+				b.addSeqRes(chainID, name)
+			}
+		case "MODRES":
+			b.addModifiedResidue(strings.TrimSpace(column(text, 13, 15)), strings.TrimSpace(column(text, 25, 27)))
+		case "MODEL":
+			var serial int
+			serial, err = strconv.Atoi(strings.TrimSpace(column(text, 11, 14)))
+			if err == nil {
+				b.startModel(serial)
+			}
+		case "ATOM", "HETATM":
+			err = parseAtomRecord(b, text)
+		case "END":
+			return b.structure, nil
+		}
+		if err != nil {
+			return nil, &ParseError{Line: line, Err: err}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b.structure, nil
+}
+
+// column returns columns first to last, counted from 1 as in the PDB format
+// specification, or as much of them as the line has.
+func column(line string, first, last int) string {
+	if first > len(line) {
+		return ""
+	}
+	if last > len(line) {
+		last = len(line)
+	}
+	return line[first-1 : last]
+}
+
+func parseAtomRecord(b *builder, line string) error {
+	if len(line) < 54 {
+		return fmt.Errorf("%s record too short for coordinates", strings.TrimSpace(column(line, 1, 6)))
+	}
+
+	atom := &Atom{
+		Name:      strings.TrimSpace(column(line, 13, 16)),
+		AltLoc:    strings.TrimSpace(column(line, 17, 17)),
+		Occupancy: 1,
+		Element:   strings.TrimSpace(column(line, 77, 78)),
+		Charge:    strings.TrimSpace(column(line, 79, 80)),
+		HetAtm:    strings.HasPrefix(line, "HETATM"),
+	}
+	// Serial numbers of very large structures overflow their five columns
+	// and are not needed to place the atom, so they are read leniently.
+	atom.Serial, _ = strconv.Atoi(strings.TrimSpace(column(line, 7, 11)))
+
+	var err error
+	coordinates := []*float64{&atom.X, &atom.Y, &atom.Z}
+	for i, coordinate := range coordinates {
+		first := 31 + 8*i
+		if *coordinate, err = parseFloat(column(line, first, first+7)); err != nil {
+			return fmt.Errorf("invalid coordinate: %w", err)
+		}
+	}
+	if value := strings.TrimSpace(column(line, 55, 60)); value != "" {
+		if atom.Occupancy, err = parseFloat(value); err != nil {
+			return fmt.Errorf("invalid occupancy: %w", err)
+		}
+	}
+	if value := strings.TrimSpace(column(line, 61, 66)); value != "" {
+		if atom.BFactor, err = parseFloat(value); err != nil {
+			return fmt.Errorf("invalid B-factor: %w", err)
+		}
+	}
+	if atom.Element == "" {
+		atom.Element = guessElement(column(line, 13, 16))
+	}
+
+	seqNum, err := strconv.Atoi(strings.TrimSpace(column(line, 23, 26)))
+	if err != nil {
+		return fmt.Errorf("invalid residue number: %w", err)
+	}
+	resName := strings.TrimSpace(column(line, 18, 20))
+	chainID := strings.TrimSpace(column(line, 22, 22))
+	iCode := strings.TrimSpace(column(line, 27, 27))
+
+	b.addAtom(chainID, resName, seqNum, iCode, atom)
+	return nil
+}
+
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(s), 64)
+}
+
+// guessElement derives the element of an atom from its name columns when
+// the element columns are empty. Element symbols of one letter start in the
+// second column of the name, those of two letters in the first.
+func guessElement(name string) string {
+	if name == "" {
+		return ""
+	}
+	trimmed := strings.TrimLeftFunc(strings.TrimSpace(name), unicode.IsDigit)
+	if trimmed == "" {
+		return ""
+	}
+	if name[0] == ' ' || unicode.IsDigit(rune(name[0])) {
+		return strings.ToUpper(trimmed[:1])
+	}
+	// Four-letter hydrogen names such as HD21 fill all name columns.
+	if len(trimmed) == 4 && trimmed[0] == 'H' {
+		return "H"
+	}
+	if len(trimmed) >= 2 {
+		return strings.ToUpper(trimmed[:2])
+	}
+	return strings.ToUpper(trimmed)
+}
@@ -0,0 +1,148 @@
+package structure
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testPDB = `HEADER    OXYGEN TRANSPORT                        07-MAR-84   1ABC              
+TITLE     THE CRYSTAL STRUCTURE OF A TEST                                       
+TITLE    2 PROTEIN                                                              
+ATOM      1  N   VAL A   1       6.204  16.869   4.854  1.00 49.05           N  
+ATOM      2  CA  VAL A   1       6.913  17.759   4.607  1.00 43.14           C  
+ATOM      3  CA AGLY A   2       8.100  18.000   5.000  0.60 20.00           C  
+ATOM      4  CA BGLY A   2       8.200  18.100   5.100  0.40 21.00           C  
+ATOM      5  CA  SER A  52A      9.000  19.000   6.000  1.00 30.00           C  
+TER       6      SER A  52A                                                     
+ATOM      7  CA  LYS B   1      10.000  20.000   7.000  1.00 30.00           C  
+HETATM    8 FE   HEM A 201      11.000  21.000   8.000  1.00 15.00          FE  
+HETATM    9  O   HOH A 301      12.000  22.000   9.000  0.50 35.00           O1-
+END                                                                             
+ATOM     10  CA  ALA A   3       0.000   0.000   0.000  1.00  0.00           C  
+`
+
+func Test_ReadPDB(t *testing.T) {
+	s, err := ReadPDB(strings.NewReader(testPDB))
+	if err != nil {
+		t.Fatalf("ReadPDB() returned error: %v", err)
+	}
+
+	if s.ID != "1ABC" || s.Title != "THE CRYSTAL STRUCTURE OF A TEST PROTEIN" {
+		t.Errorf("Unexpected header: %q, %q", s.ID, s.Title)
+	}
+	if len(s.Models) != 1 || s.Models[0].Serial != 1 {
+		t.Fatalf("Expected a single model 1, got %d models", len(s.Models))
+	}
+
+	model := s.Models[0]
+	if len(model.Chains) != 2 || model.Chains[0].ID != "A" || model.Chains[1].ID != "B" {
+		t.Fatalf("Expected chains A and B, got %d chains", len(model.Chains))
+	}
+
+	chainA := model.Chain("A")
+	var residues []string
+	for _, r := range chainA.Residues {
+		residues = append(residues, r.Name+r.ID())
+	}
+	if got := strings.Join(residues, " "); got != "VAL1 GLY2 SER52A HEM201 HOH301" {
+		t.Errorf("Unexpected residues in chain A: %s", got)
+	}
+
+	gly := chainA.Residues[1]
+	if len(gly.Atoms) != 2 || strings.Join(gly.AltLocs(), "") != "AB" {
+		t.Errorf("Expected two alternate locations for GLY2, got %d atoms", len(gly.Atoms))
+	}
+	if ca := gly.Atom("CA"); ca.AltLoc != "A" || ca.Occupancy != 0.6 || ca.BFactor != 20 {
+		t.Errorf("Expected the altloc with the highest occupancy, got %+v", ca)
+	}
+
+	heme := chainA.Residues[3]
+	fe := heme.Atoms[0]
+	if !heme.HetAtm || !fe.HetAtm || fe.Name != "FE" || fe.Element != "FE" || fe.X != 11 || fe.Serial != 8 {
+		t.Errorf("Unexpected heme atom: %+v", fe)
+	}
+	if water := chainA.Residues[4].Atoms[0]; water.Charge != "1-" || water.Occupancy != 0.5 {
+		t.Errorf("Unexpected water atom: %+v", water)
+	}
+
+	if n := len(model.Atoms()); n != 8 {
+		t.Errorf("Expected 8 atoms before END, got %d", n)
+	}
+}
+
+func Test_ReadPDB_models(t *testing.T) {
+	input := `MODEL        1
+ATOM      1  CA  GLY A   1       1.000   1.000   1.000  1.00  0.00           C
+ENDMDL
+MODEL        2
+ATOM      1  CA  GLY A   1       2.000   2.000   2.000  1.00  0.00           C
+ENDMDL
+`
+	s, err := ReadPDB(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadPDB() returned error: %v", err)
+	}
+	if len(s.Models) != 2 || s.Models[1].Serial != 2 {
+		t.Fatalf("Expected 2 models, got %d", len(s.Models))
+	}
+	if a := s.Models[1].Atoms()[0]; a.X != 2 {
+		t.Errorf("Expected the coordinates of model 2, got %+v", a)
+	}
+}
+
+func Test_ReadPDB_errors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		line  int
+	}{
+		{
+			name:  "Truncated line",
+			input: "HEADER    TEST\nATOM      1  CA  GLY A   1       1.000   1.000\n",
+			line:  2,
+		},
+		{
+			name:  "Invalid coordinate",
+			input: "ATOM      1  CA  GLY A   1       1.000   x.xxx   1.000  1.00  0.00           C\n",
+			line:  1,
+		},
+		{
+			name:  "Invalid residue number",
+			input: "ATOM      1  CA  GLY A   X       1.000   1.000   1.000  1.00  0.00           C\n",
+			line:  1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ReadPDB(strings.NewReader(tc.input))
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Line != tc.line {
+				t.Errorf("Expected parse error on line %d, got %v", tc.line, err)
+			}
+		})
+	}
+}
+
+func Test_guessElement(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{" CA ", "C"},
+		{"CA  ", "CA"},
+		{"FE  ", "FE"},
+		{" N  ", "N"},
+		{"1HG1", "H"},
+		{"HD21", "H"},
+		{" O  ", "O"},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		if element := guessElement(tc.name); element != tc.expected {
+			t.Errorf("guessElement(%q): expected %q, got %q", tc.name, tc.expected, element)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package structure
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RadiiTable maps element symbols, in upper case, to van der Waals radii in
+// Å.
+type RadiiTable map[string]float64
+
+// RadiiBondi are the radii of Bondi (1964).
+var RadiiBondi = RadiiTable{
+	"H": 1.20, "C": 1.70, "N": 1.55, "O": 1.52, "F": 1.47, "P": 1.80, "S": 1.80,
+	"CL": 1.75, "BR": 1.85, "I": 1.98, "SE": 1.90, "NA": 2.27, "MG": 1.73, "K": 2.75,
+	"NI": 1.63, "CU": 1.40, "ZN": 1.39,
+}
+
+// RadiiAlvarez are the radii of Alvarez (2013).
+var RadiiAlvarez = RadiiTable{
+	"H": 1.20, "C": 1.77, "N": 1.66, "O": 1.50, "F": 1.46, "P": 1.90, "S": 1.89,
+	"CL": 1.82, "BR": 1.86, "I": 2.04, "SE": 1.82, "NA": 2.50, "MG": 2.51, "K": 2.73,
+	"CA": 2.62, "MN": 2.45, "FE": 2.44, "CO": 2.40, "NI": 2.40, "CU": 2.38, "ZN": 2.39,
+}
+
+// UnknownElementRadius is used for elements missing from a radii table.
+const UnknownElementRadius = 1.80
+
+// ParseRadiiTable returns a built-in radii table by name: bondi or alvarez.
+func ParseRadiiTable(name string) (RadiiTable, error) {
+	switch strings.ToLower(name) {
+	case "bondi":
+		return RadiiBondi, nil
+	case "alvarez":
+		return RadiiAlvarez, nil
+	}
+	return nil, fmt.Errorf("unknown radii table %q, expected bondi, alvarez or a file", name)
+}
+
+// ReadRadiiTable reads a radii table with an element and a radius on every
+// line, such as "SE 1.90". Blank lines and lines starting with # are
+// skipped.
+func ReadRadiiTable(r io.Reader) (RadiiTable, error) {
+	table := make(RadiiTable)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected an element and a radius, got %q", n, line)
+		}
+		radius, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || radius <= 0 {
+			return nil, fmt.Errorf("line %d: invalid radius %q", n, fields[1])
+		}
+		table[strings.ToUpper(fields[0])] = radius
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(table) == 0 {
+		return nil, fmt.Errorf("no radii found")
+	}
+	return table, nil
+}
+
+// Radius returns the radius of an element, or UnknownElementRadius and
+// false if the table does not have it.
+func (t RadiiTable) Radius(element string) (float64, bool) {
+	if radius, ok := t[strings.ToUpper(element)]; ok {
+		return radius, true
+	}
+	return UnknownElementRadius, false
+}
+
+// maxResidueAreas are the largest SASAs of residues in a Gly-X-Gly
+// tripeptide, in Å², from the theoretical values of Tien et al. (2013).
+var maxResidueAreas = map[string]float64{
+	"ALA": 129, "ARG": 274, "ASN": 195, "ASP": 193, "CYS": 167,
+	"GLN": 225, "GLU": 223, "GLY": 104, "HIS": 224, "ILE": 197,
+	"LEU": 201, "LYS": 236, "MET": 224, "PHE": 240, "PRO": 159,
+	"SER": 155, "THR": 172, "TRP": 285, "TYR": 263, "VAL": 174,
+}
+
+// Defaults of SASAOptions.
+const (
+	DefaultProbeRadius = 1.4
+	DefaultSASAPoints  = 100
+)
+
+// SASAOptions configure the Shrake–Rupley calculation.
+type SASAOptions struct {
+	// Probe is the radius of the solvent molecule in Å.
+	Probe float64
+	// Points is the number of test points on the sphere of every atom.
+	Points int
+	Radii  RadiiTable
+	// Jobs is the number of atoms computed in parallel.
+	Jobs int
+	// Hydrogens includes hydrogen atoms, which most radii tables assume to
+	// be absent.
+	Hydrogens bool
+	// SkipHetero leaves out ligands and other HETATM residues that are not
+	// part of a polymer.
+	SkipHetero bool
+}
+
+// AtomArea is the solvent accessible surface area of an atom in Å².
+type AtomArea struct {
+	Chain   *Chain
+	Residue *Residue
+	Atom    *Atom
+	Radius  float64
+	Area    float64
+}
+
+// ResidueArea is the solvent accessible surface area of a residue in Å², and
+// that area relative to the largest one of its residue type, or NaN for
+// residues other than amino acids.
+type ResidueArea struct {
+	Chain    *Chain
+	Residue  *Residue
+	Area     float64
+	Relative float64
+}
+
+// SASA computes the solvent accessible surface area of the atoms in the
+// first model of s with the Shrake–Rupley algorithm. Waters are left out. Of
+// alternate locations, only the one with the highest total occupancy is used,
+// chosen like in Clean across the residues sharing a number, so that of a
+// point mutation modeled as two residues only one is included.
+func (s *Structure) SASA(opts SASAOptions) ([]AtomArea, error) {
+	if opts.Probe < 0 {
+		return nil, fmt.Errorf("invalid probe radius %g", opts.Probe)
+	}
+	if opts.Points < 1 {
+		return nil, fmt.Errorf("invalid number of points %d", opts.Points)
+	}
+	if len(s.Models) == 0 {
+		return nil, fmt.Errorf("no atoms")
+	}
+
+	var areas []AtomArea
+	for _, c := range s.Models[0].Chains {
+		groups := make(map[string][]*Residue)
+		for _, r := range c.Residues {
+			groups[r.ID()] = append(groups[r.ID()], r)
+		}
+
+		for _, r := range c.Residues {
+			if r.IsWater() || (opts.SkipHetero && r.HetAtm && s.Parent(r.Name) == "") {
+				continue
+			}
+			altLoc := highestAltLoc(groups[r.ID()])
+			seen := make(map[string]bool)
+			for _, a := range r.Atoms {
+				if seen[a.Name] || (a.AltLoc != "" && a.AltLoc != altLoc) ||
+					(!opts.Hydrogens && (a.Element == "H" || a.Element == "D")) {
+					continue
+				}
+				seen[a.Name] = true
+				radius, _ := opts.Radii.Radius(a.Element)
+				if radius <= 0 {
+					return nil, fmt.Errorf("invalid radius %g of element %s", radius, a.Element)
+				}
+				areas = append(areas, AtomArea{Chain: c, Residue: r, Atom: a, Radius: radius})
+			}
+		}
+	}
+	if len(areas) == 0 {
+		return nil, fmt.Errorf("no atoms")
+	}
+
+	atoms := make([]*Atom, len(areas))
+	radii := make([]float64, len(areas))
+	for i, a := range areas {
+		atoms[i], radii[i] = a.Atom, a.Radius
+	}
+	for i, area := range ShrakeRupley(atoms, radii, opts.Probe, opts.Points, opts.Jobs) {
+		areas[i].Area = area
+	}
+	return areas, nil
+}
+
+// ResidueAreas sums atom areas by residue, keeping the order of the atoms.
+func (s *Structure) ResidueAreas(atoms []AtomArea) []ResidueArea {
+	var residues []ResidueArea
+	for _, a := range atoms {
+		if n := len(residues); n > 0 && residues[n-1].Residue == a.Residue {
+			residues[n-1].Area += a.Area
+			continue
+		}
+		residues = append(residues, ResidueArea{Chain: a.Chain, Residue: a.Residue, Area: a.Area})
+	}
+	for i := range residues {
+		residues[i].Relative = math.NaN()
+		if max, ok := maxResidueAreas[s.Parent(residues[i].Residue.Name)]; ok {
+			residues[i].Relative = residues[i].Area / max
+		}
+	}
+	return residues
+}
+
+// ShrakeRupley returns the solvent accessible surface area of each atom with
+// the given radius: the part of a sphere of the radius plus probe that is not
+// inside the spheres of other atoms, estimated from points evenly spread over
+// it. Atoms are computed by jobs goroutines.
+func ShrakeRupley(atoms []*Atom, radii []float64, probe float64, points, jobs int) []float64 {
+	if jobs < 1 {
+		jobs = 1
+	}
+	sphere := spherePoints(points)
+
+	// Atoms are put in cubic cells at least as large as the largest distance
+	// at which two spheres touch, so that neighbors are in adjacent cells.
+	expanded := make([]float64, len(atoms))
+	maxRadius := 0.0
+	for i, r := range radii {
+		expanded[i] = r + probe
+		maxRadius = math.Max(maxRadius, expanded[i])
+	}
+	cellSize := 2 * maxRadius
+	if cellSize <= 0 {
+		// Without radii or a probe, atoms have no area to compute.
+		cellSize = 1
+	}
+	cellOf := func(a *Atom) [3]int {
+		return [3]int{int(math.Floor(a.X / cellSize)), int(math.Floor(a.Y / cellSize)), int(math.Floor(a.Z / cellSize))}
+	}
+	cells := make(map[[3]int][]int)
+	for i, a := range atoms {
+		cell := cellOf(a)
+		cells[cell] = append(cells[cell], i)
+	}
+
+	areas := make([]float64, len(atoms))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var neighbors []int
+			for i := range indexes {
+				a, ri := atoms[i], expanded[i]
+
+				neighbors = neighbors[:0]
+				cell := cellOf(a)
+				for dx := -1; dx <= 1; dx++ {
+					for dy := -1; dy <= 1; dy++ {
+						for dz := -1; dz <= 1; dz++ {
+							for _, j := range cells[[3]int{cell[0] + dx, cell[1] + dy, cell[2] + dz}] {
+								b := atoms[j]
+								x, y, z := a.X-b.X, a.Y-b.Y, a.Z-b.Z
+								if j != i && x*x+y*y+z*z < (ri+expanded[j])*(ri+expanded[j]) {
+									neighbors = append(neighbors, j)
+								}
+							}
+						}
+					}
+				}
+
+				exposed := 0
+				// A point is often buried by the same atom as the one before.
+				last := 0
+				for _, p := range sphere {
+					x, y, z := a.X+ri*p[0], a.Y+ri*p[1], a.Z+ri*p[2]
+					buried := false
+					for k := range neighbors {
+						j := neighbors[(last+k)%len(neighbors)]
+						b, rj := atoms[j], expanded[j]
+						dx, dy, dz := x-b.X, y-b.Y, z-b.Z
+						if dx*dx+dy*dy+dz*dz < rj*rj {
+							buried = true
+							last = (last + k) % len(neighbors)
+							break
+						}
+					}
+					if !buried {
+						exposed++
+					}
+				}
+				areas[i] = 4 * math.Pi * ri * ri * float64(exposed) / float64(len(sphere))
+			}
+		}()
+	}
+
+	for i := range atoms {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return areas
+}
+
+// spherePoints spreads n points evenly over the unit sphere along a golden
+// section spiral.
+func spherePoints(n int) [][3]float64 {
+	points := make([][3]float64, n)
+	increment := math.Pi * (3 - math.Sqrt(5))
+	for i := range points {
+		y := 1 - (2*float64(i)+1)/float64(n)
+		r := math.Sqrt(1 - y*y)
+		phi := float64(i) * increment
+		points[i] = [3]float64{math.Cos(phi) * r, y, math.Sin(phi) * r}
+	}
+	return points
+}
@@ -0,0 +1,173 @@
+package structure
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func Test_ShrakeRupley(t *testing.T) {
+	const probe = 1.4
+	radius := 1.7 + probe
+	sphere := 4 * math.Pi * radius * radius
+
+	testCases := []struct {
+		name     string
+		distance float64
+		expected float64
+	}{
+		{name: "apart", distance: 10, expected: sphere},
+		// Each sphere loses a cap of height radius - distance/2.
+		{name: "overlapping", distance: 4, expected: sphere - 2*math.Pi*radius*(radius-2)},
+		{name: "touching", distance: 2 * radius, expected: sphere},
+	}
+
+	for _, tc := range testCases {
+		atoms := []*Atom{{X: 0, Y: 0, Z: 0}, {X: tc.distance, Y: 0, Z: 0}}
+		areas := ShrakeRupley(atoms, []float64{1.7, 1.7}, probe, 2000, 2)
+		for i, area := range areas {
+			if math.Abs(area-tc.expected) > 0.01*tc.expected {
+				t.Errorf("%s: expected an area of %.1f for atom %d, got %.1f", tc.name, tc.expected, i, area)
+			}
+		}
+	}
+}
+
+func Test_ShrakeRupley_jobs(t *testing.T) {
+	var atoms []*Atom
+	var radii []float64
+	for i := 0; i < 60; i++ {
+		f := float64(i)
+		atoms = append(atoms, &Atom{X: math.Mod(f*1.3, 7), Y: math.Mod(f*2.9, 5), Z: f * 0.4})
+		radii = append(radii, 1.5+math.Mod(f, 3)*0.1)
+	}
+
+	serial := ShrakeRupley(atoms, radii, 1.4, 100, 1)
+	parallel := ShrakeRupley(atoms, radii, 1.4, 100, 8)
+	for i := range serial {
+		if serial[i] != parallel[i] {
+			t.Errorf("Atom %d: expected the same area with 1 and 8 jobs, got %g and %g", i, serial[i], parallel[i])
+		}
+	}
+}
+
+func Test_ShrakeRupley_zeroRadii(t *testing.T) {
+	atoms := []*Atom{{X: -3, Y: 1, Z: 0}, {X: 0, Y: 0, Z: 0}, {X: 0.5, Y: 0, Z: 0}, {X: 12, Y: -7, Z: 4}}
+	areas := ShrakeRupley(atoms, make([]float64, len(atoms)), 0, 50, 2)
+	for i, area := range areas {
+		if area != 0 {
+			t.Errorf("Atom %d: expected no area without radii or a probe, got %g", i, area)
+		}
+	}
+}
+
+func Test_SASA(t *testing.T) {
+	s, err := ReadPDB(strings.NewReader(testPDB))
+	if err != nil {
+		t.Fatalf("ReadPDB() returned error: %v", err)
+	}
+
+	atoms, err := s.SASA(SASAOptions{Probe: DefaultProbeRadius, Points: DefaultSASAPoints, Radii: RadiiBondi})
+	if err != nil {
+		t.Fatalf("SASA() returned error: %v", err)
+	}
+	// The water and the second alternate location of GLY 2 are left out.
+	var names []string
+	for _, a := range atoms {
+		names = append(names, a.Residue.Name+a.Residue.ID()+":"+a.Atom.Name+a.Atom.AltLoc)
+		if a.Area <= 0 {
+			t.Errorf("Expected %s %s to be exposed, got %g", a.Residue.Name, a.Atom.Name, a.Area)
+		}
+	}
+	expected := "VAL1:N VAL1:CA GLY2:CAA SER52A:CA HEM201:FE LYS1:CA"
+	if got := strings.Join(names, " "); got != expected {
+		t.Errorf("Expected atoms %q, got %q", expected, got)
+	}
+	if atoms[4].Radius != UnknownElementRadius {
+		t.Errorf("Expected the radius of FE to be unknown, got %g", atoms[4].Radius)
+	}
+
+	residues := s.ResidueAreas(atoms)
+	if len(residues) != 5 || residues[0].Area != atoms[0].Area+atoms[1].Area {
+		t.Fatalf("Unexpected residue areas: %+v", residues)
+	}
+	if relative := residues[1].Relative; math.Abs(relative-residues[1].Area/104) > 1e-9 {
+		t.Errorf("Expected GLY 2 relative to 104 Å², got %g", relative)
+	}
+	if !math.IsNaN(residues[3].Relative) {
+		t.Errorf("Expected no relative area for HEM, got %g", residues[3].Relative)
+	}
+
+	skipped, err := s.SASA(SASAOptions{Probe: DefaultProbeRadius, Points: DefaultSASAPoints, Radii: RadiiBondi, SkipHetero: true})
+	if err != nil || len(skipped) != len(atoms)-1 {
+		t.Errorf("Expected HEM to be skipped, got %d atoms, %v", len(skipped), err)
+	}
+	if _, err := s.SASA(SASAOptions{Probe: 1.4}); err == nil {
+		t.Errorf("Expected an error without points")
+	}
+	zero := RadiiTable{"C": 0, "N": 1.55, "FE": 1.4}
+	if _, err := s.SASA(SASAOptions{Probe: 0, Points: DefaultSASAPoints, Radii: zero}); err == nil {
+		t.Errorf("Expected an error for a radius of 0")
+	}
+}
+
+func Test_ReadRadiiTable(t *testing.T) {
+	table, err := ReadRadiiTable(strings.NewReader("# element radius\nC 1.7\n\nse 1.9\n"))
+	if err != nil {
+		t.Fatalf("ReadRadiiTable() returned error: %v", err)
+	}
+	if r, ok := table.Radius("Se"); !ok || r != 1.9 {
+		t.Errorf("Expected SE 1.9, got %g, %v", r, ok)
+	}
+	if _, ok := table.Radius("N"); ok {
+		t.Errorf("Expected N to be missing")
+	}
+
+	for _, input := range []string{"", "C\n", "C x\n", "C -1\n"} {
+		if _, err := ReadRadiiTable(strings.NewReader(input)); err == nil {
+			t.Errorf("ReadRadiiTable(%q): expected an error", input)
+		}
+	}
+	if _, err := ParseRadiiTable("Bondi"); err != nil {
+		t.Errorf("ParseRadiiTable() returned error: %v", err)
+	}
+}
+
+func Test_SASA_microheterogeneity(t *testing.T) {
+	// Residue 2 is modeled as serine (A, occupancy 0.4) and threonine (B,
+	// occupancy 0.6) at the same place.
+	input := `ATOM      1  CA  GLY A   1       0.000   0.000   0.000  1.00 10.00           C  
+ATOM      2  N  ASER A   2       3.000   0.000   0.000  0.40 10.00           N  
+ATOM      3  CA ASER A   2       3.800   1.000   0.000  0.40 10.00           C  
+ATOM      4  OG ASER A   2       4.500   2.000   0.500  0.40 10.00           O  
+ATOM      5  N  BTHR A   2       3.000   0.000   0.000  0.60 10.00           N  
+ATOM      6  CA BTHR A   2       3.800   1.000   0.000  0.60 10.00           C  
+ATOM      7  OG1BTHR A   2       4.500   2.000   0.500  0.60 10.00           O  
+ATOM      8  CG2BTHR A   2       4.800   0.000   1.000  0.60 10.00           C  
+ATOM      9  CA  ALA A   3       7.000   1.000   0.000  1.00 10.00           C  
+END
+`
+	s, err := ReadPDB(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadPDB() returned error: %v", err)
+	}
+	if n := len(s.Models[0].Chains[0].Residues); n != 4 {
+		t.Fatalf("Expected the alternates of residue 2 as separate residues, got %d residues", n)
+	}
+
+	atoms, err := s.SASA(SASAOptions{Probe: DefaultProbeRadius, Points: DefaultSASAPoints, Radii: RadiiBondi})
+	if err != nil {
+		t.Fatalf("SASA() returned error: %v", err)
+	}
+	var names []string
+	for _, a := range atoms {
+		names = append(names, a.Residue.Name+a.Residue.ID()+":"+a.Atom.Name)
+	}
+	expected := "GLY1:CA THR2:N THR2:CA THR2:OG1 THR2:CG2 ALA3:CA"
+	if got := strings.Join(names, " "); got != expected {
+		t.Errorf("Expected atoms %q, got %q", expected, got)
+	}
+	if residues := s.ResidueAreas(atoms); len(residues) != 3 || residues[1].Residue.Name != "THR" {
+		t.Errorf("Expected one area for residue 2, got %+v", residues)
+	}
+}
@@ -0,0 +1,103 @@
+package structure
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Selection is a chain, optionally limited to a range of residue numbers, as
+// written in B:10-150.
+type Selection struct {
+	Chain      string
+	Start, End int
+	// Range is false if the whole chain is selected.
+	Range bool
+}
+
+var residueRangePattern = regexp.MustCompile(`^(-?\d+)-(-?\d+)$`)
+
+// ParseSelection parses a chain selection such as A or B:10-150. Ranges
+// include both ends and may have negative residue numbers, e.g. A:-5-20.
+// Chain IDs name output files, so path separators and .. are rejected.
+func ParseSelection(text string) (Selection, error) {
+	chain, residues, hasRange := strings.Cut(text, ":")
+	if chain == "" || strings.ContainsAny(chain, " \t/\\") || strings.Contains(chain, "..") {
+		return Selection{}, fmt.Errorf("invalid chain selection %q", text)
+	}
+	sel := Selection{Chain: chain}
+	if !hasRange {
+		return sel, nil
+	}
+
+	match := residueRangePattern.FindStringSubmatch(residues)
+	if match == nil {
+		return Selection{}, fmt.Errorf("invalid residue range %q in %q, expected e.g. 10-150", residues, text)
+	}
+	sel.Start, _ = strconv.Atoi(match[1])
+	sel.End, _ = strconv.Atoi(match[2])
+	if sel.Start > sel.End {
+		return Selection{}, fmt.Errorf("invalid residue range %q in %q: start is after end", residues, text)
+	}
+	sel.Range = true
+	return sel, nil
+}
+
+func (sel Selection) String() string {
+	if !sel.Range {
+		return sel.Chain
+	}
+	return fmt.Sprintf("%s:%d-%d", sel.Chain, sel.Start, sel.End)
+}
+
+// Contains reports whether r is in the residue range of the selection. It
+// does not check the chain.
+func (sel Selection) Contains(r *Residue) bool {
+	return !sel.Range || (r.SeqNum >= sel.Start && r.SeqNum <= sel.End)
+}
+
+// Extract returns a copy of s with only the selected chain and residues in
+// every model. Ligands and waters of the chain are kept if they are in the
+// range, and the full sequence of the chain if it is selected as a whole.
+// The residues themselves are shared with s.
+func Extract(s *Structure, sel Selection) (*Structure, error) {
+	extracted := &Structure{
+		ID: s.ID, Title: s.Title, Method: s.Method, Resolution: s.Resolution,
+		ModifiedResidues: s.ModifiedResidues,
+	}
+	// The full sequence only describes whole chains.
+	if seq := s.Sequence(sel.Chain); seq != nil && !sel.Range {
+		extracted.Sequences = []*Sequence{seq}
+	}
+	found := false
+	for _, m := range s.Models {
+		model := &Model{Serial: m.Serial}
+		extracted.Models = append(extracted.Models, model)
+
+		c := m.Chain(sel.Chain)
+		if c == nil {
+			continue
+		}
+		found = true
+		chain := &Chain{ID: c.ID}
+		for _, r := range c.Residues {
+			if sel.Contains(r) {
+				chain.Residues = append(chain.Residues, r)
+			}
+		}
+		if len(chain.Residues) > 0 {
+			model.Chains = append(model.Chains, chain)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("chain %s not found", sel.Chain)
+	}
+	for _, m := range extracted.Models {
+		if len(m.Chains) > 0 {
+			return extracted, nil
+		}
+	}
+	return nil, fmt.Errorf("no residues in %s", sel)
+}
@@ -0,0 +1,89 @@
+package structure
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ParseSelection(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected Selection
+		err      bool
+	}{
+		{text: "A", expected: Selection{Chain: "A"}},
+		{text: "B:10-150", expected: Selection{Chain: "B", Start: 10, End: 150, Range: true}},
+		{text: "AA:-5-20", expected: Selection{Chain: "AA", Start: -5, End: 20, Range: true}},
+		{text: "a:-10--2", expected: Selection{Chain: "a", Start: -10, End: -2, Range: true}},
+		{text: "", err: true},
+		{text: ":1-10", err: true},
+		{text: "B:10", err: true},
+		{text: "B:x-10", err: true},
+		{text: "B:150-10", err: true},
+		{text: "../A", err: true},
+		{text: "A/B:1-10", err: true},
+		{text: `A\B`, err: true},
+		{text: "..", err: true},
+	}
+
+	for _, tc := range testCases {
+		sel, err := ParseSelection(tc.text)
+		if tc.err {
+			if err == nil {
+				t.Errorf("ParseSelection(%q): expected an error, got %+v", tc.text, sel)
+			}
+			continue
+		}
+		if err != nil || sel != tc.expected {
+			t.Errorf("ParseSelection(%q): expected %+v, got %+v, %v", tc.text, tc.expected, sel, err)
+		}
+		if sel.String() != tc.text {
+			t.Errorf("Selection.String(): expected %q, got %q", tc.text, sel.String())
+		}
+	}
+}
+
+func Test_Extract(t *testing.T) {
+	s, err := ReadPDB(strings.NewReader(testPDB))
+	if err != nil {
+		t.Fatalf("ReadPDB() returned error: %v", err)
+	}
+
+	testCases := []struct {
+		selection Selection
+		expected  string
+		err       bool
+	}{
+		{selection: Selection{Chain: "A"}, expected: "VAL1 GLY2 SER52A HEM201 HOH301"},
+		{selection: Selection{Chain: "A", Start: 2, End: 52, Range: true}, expected: "GLY2 SER52A"},
+		{selection: Selection{Chain: "B"}, expected: "LYS1"},
+		{selection: Selection{Chain: "C"}, err: true},
+		{selection: Selection{Chain: "A", Start: 60, End: 100, Range: true}, err: true},
+	}
+
+	for _, tc := range testCases {
+		extracted, err := Extract(s, tc.selection)
+		if tc.err {
+			if err == nil {
+				t.Errorf("Extract(%s): expected an error", tc.selection)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Extract(%s) returned error: %v", tc.selection, err)
+		}
+		if extracted.ID != "1ABC" || len(extracted.Models) != 1 || len(extracted.Models[0].Chains) != 1 {
+			t.Fatalf("Extract(%s): expected a single chain of 1ABC, got %+v", tc.selection, extracted)
+		}
+		var residues []string
+		for _, r := range extracted.Models[0].Chains[0].Residues {
+			residues = append(residues, r.Name+r.ID())
+		}
+		if got := strings.Join(residues, " "); got != tc.expected {
+			t.Errorf("Extract(%s): expected %q, got %q", tc.selection, tc.expected, got)
+		}
+	}
+	if n := len(s.Models[0].Chains); n != 2 {
+		t.Errorf("Expected the original structure to be unchanged, got %d chains", n)
+	}
+}
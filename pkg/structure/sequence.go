@@ -0,0 +1,107 @@
+package structure
+
+// Polymer types of chains, as in the mol field of the PDB's seqres FASTA
+// files.
+const (
+	MolProtein     = "protein"
+	MolNucleicAcid = "na"
+)
+
+var aminoAcids = map[string]byte{
+	"ALA": 'A', "ARG": 'R', "ASN": 'N', "ASP": 'D', "CYS": 'C',
+	"GLN": 'Q', "GLU": 'E', "GLY": 'G', "HIS": 'H', "ILE": 'I',
+	"LEU": 'L', "LYS": 'K', "MET": 'M', "PHE": 'F', "PRO": 'P',
+	"SER": 'S', "THR": 'T', "TRP": 'W', "TYR": 'Y', "VAL": 'V',
+	"SEC": 'U', "PYL": 'O', "ASX": 'B', "GLX": 'Z', "UNK": 'X',
+}
+
+var nucleotides = map[string]byte{
+	"A": 'A', "C": 'C', "G": 'G', "U": 'U', "I": 'I', "N": 'N',
+	"DA": 'A', "DC": 'C', "DG": 'G', "DT": 'T', "DU": 'U', "DI": 'I', "DN": 'N',
+}
+
+// knownModifiedResidues are the standard parents of common modified residues,
+// used for files without MODRES records.
+var knownModifiedResidues = map[string]string{
+	"MSE": "MET", "MHO": "MET", "FME": "MET", "MED": "MET",
+	"MLY": "LYS", "M3L": "LYS", "MLZ": "LYS", "KCX": "LYS", "LLP": "LYS", "ALY": "LYS", "DLY": "LYS",
+	"SEP": "SER", "DSN": "SER", "TPO": "THR", "DTH": "THR", "PTR": "TYR", "DTY": "TYR",
+	"HYP": "PRO", "DPR": "PRO", "PCA": "GLN", "DGN": "GLN", "CGU": "GLU", "DGL": "GLU",
+	"CSO": "CYS", "CSD": "CYS", "CME": "CYS", "OCS": "CYS", "CSS": "CYS", "CAS": "CYS", "SMC": "CYS", "DCY": "CYS",
+	"HIC": "HIS", "NEP": "HIS", "DHI": "HIS", "DAL": "ALA", "DAR": "ARG", "DAS": "ASP", "DSG": "ASN",
+	"DVA": "VAL", "DLE": "LEU", "DIL": "ILE", "DPN": "PHE", "DTR": "TRP",
+	"PSU": "U", "H2U": "U", "4SU": "U", "5MU": "U", "5MC": "C", "OMC": "C",
+	"1MA": "A", "2MG": "G", "M2G": "G", "7MG": "G", "OMG": "G",
+}
+
+// Parent returns the standard residue that a residue name stands for: the
+// name itself for standard residues, the parent of modified residues, or ""
+// if it is not known.
+func (s *Structure) Parent(name string) string {
+	if _, ok := aminoAcids[name]; ok {
+		return name
+	}
+	if _, ok := nucleotides[name]; ok {
+		return name
+	}
+	if parent, ok := s.ModifiedResidues[name]; ok {
+		return parent
+	}
+	return knownModifiedResidues[name]
+}
+
+// OneLetterCode returns the one-letter code of a residue, mapping modified
+// residues to their parents, and the type of polymer it belongs to. Unknown
+// residues are X and have no type.
+func (s *Structure) OneLetterCode(name string) (byte, string) {
+	parent := s.Parent(name)
+	if code, ok := aminoAcids[parent]; ok {
+		return code, MolProtein
+	}
+	if code, ok := nucleotides[parent]; ok {
+		return code, MolNucleicAcid
+	}
+	return 'X', ""
+}
+
+// OneLetterSequence converts residue names to one-letter codes and returns
+// the sequence together with the type of polymer most of the residues
+// belong to, protein if none is known.
+func (s *Structure) OneLetterSequence(names []string) (string, string) {
+	codes := make([]byte, len(names))
+	counts := make(map[string]int)
+	for i, name := range names {
+		var mol string
+		codes[i], mol = s.OneLetterCode(name)
+		counts[mol]++
+	}
+
+	mol := MolProtein
+	if counts[MolNucleicAcid] > counts[MolProtein] {
+		mol = MolNucleicAcid
+		// Unknown nucleotides are N rather than X.
+		for i, code := range codes {
+			if code == 'X' {
+				codes[i] = 'N'
+			}
+		}
+	}
+	return string(codes), mol
+}
+
+// PolymerResidues returns the residues of c that are part of the polymer:
+// standard and modified residues with coordinates, but not ligands or
+// waters. Of residues with several alternatives at one position, only the
+// first is returned.
+func (s *Structure) PolymerResidues(c *Chain) []*Residue {
+	var residues []*Residue
+	seen := make(map[string]bool)
+	for _, r := range c.Residues {
+		if r.IsWater() || (r.HetAtm && s.Parent(r.Name) == "") || seen[r.ID()] {
+			continue
+		}
+		seen[r.ID()] = true
+		residues = append(residues, r)
+	}
+	return residues
+}
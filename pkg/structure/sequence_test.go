@@ -0,0 +1,142 @@
+package structure
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSeqresPDB = `HEADER    TEST                                    01-JAN-00   1ABC              
+SEQRES   1 A   14  MET GLY MSE SER ALA LYS GLY LEU ALA SER THR PRO HIS          
+SEQRES   2 A   14  TYR                                                          
+SEQRES   1 B    3   DA  DC  DG                                                  
+MODRES 1ABC MSE A    3  MET  SELENOMETHIONINE                                   
+ATOM      1  CA  MET A   1       1.000   1.000   1.000  1.00 10.00           C  
+ATOM      2  CA  GLY A   2       2.000   1.000   1.000  1.00 10.00           C  
+HETATM    3  CA  MSE A   3       3.000   1.000   1.000  1.00 10.00           C  
+ATOM      4  CA  ALA A   5       4.000   1.000   1.000  1.00 10.00           C  
+HETATM    5  C1  GOL A 101       5.000   1.000   1.000  1.00 10.00           C  
+HETATM    6  O   HOH A 201       6.000   1.000   1.000  1.00 10.00           O  
+ATOM      7  P    DA B   1       7.000   1.000   1.000  1.00 10.00           P  
+END
+`
+
+func Test_ReadPDB_sequences(t *testing.T) {
+	s, err := ReadPDB(strings.NewReader(testSeqresPDB))
+	if err != nil {
+		t.Fatalf("ReadPDB() returned error: %v", err)
+	}
+
+	if len(s.Sequences) != 2 || len(s.Sequence("A").Residues) != 14 || s.Sequence("A").Residues[13] != "TYR" {
+		t.Errorf("Unexpected sequences: %+v", s.Sequences)
+	}
+	if !reflect.DeepEqual(s.Sequence("B").Residues, []string{"DA", "DC", "DG"}) {
+		t.Errorf("Unexpected sequence of chain B: %v", s.Sequence("B").Residues)
+	}
+	if !reflect.DeepEqual(s.ModifiedResidues, map[string]string{"MSE": "MET"}) {
+		t.Errorf("Unexpected modified residues: %v", s.ModifiedResidues)
+	}
+}
+
+func Test_ReadCIF_sequences(t *testing.T) {
+	input := `data_1ABC
+loop_
+_pdbx_poly_seq_scheme.asym_id
+_pdbx_poly_seq_scheme.entity_id
+_pdbx_poly_seq_scheme.seq_id
+_pdbx_poly_seq_scheme.mon_id
+_pdbx_poly_seq_scheme.pdb_strand_id
+A 1 1 MET X
+A 1 2 MSE X
+A 1 3 SER X
+A 1 3 THR X
+B 2 1 DA Y
+#
+_pdbx_struct_mod_residue.id 1
+_pdbx_struct_mod_residue.auth_comp_id MSE
+_pdbx_struct_mod_residue.parent_comp_id MET
+#
+_atom_site.group_PDB ATOM
+_atom_site.Cartn_x 1.0
+_atom_site.Cartn_y 2.0
+_atom_site.Cartn_z 3.0
+_atom_site.auth_asym_id X
+_atom_site.auth_seq_id 1
+_atom_site.auth_comp_id MET
+_atom_site.auth_atom_id CA
+`
+	s, err := ReadCIF(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadCIF() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(s.Sequence("X").Residues, []string{"MET", "MSE", "SER"}) || s.Sequence("Y") == nil {
+		t.Errorf("Unexpected sequences: %+v", s.Sequences)
+	}
+	if s.ModifiedResidues["MSE"] != "MET" {
+		t.Errorf("Expected the modified residue from single items, got %v", s.ModifiedResidues)
+	}
+	if atoms := s.Models[0].Atoms(); len(atoms) != 1 || atoms[0].Z != 3 {
+		t.Errorf("Expected the atom from single items, got %d atoms", len(atoms))
+	}
+}
+
+func Test_OneLetterSequence(t *testing.T) {
+	s := &Structure{ModifiedResidues: map[string]string{"XYZ": "TRP"}}
+	testCases := []struct {
+		names    []string
+		sequence string
+		mol      string
+	}{
+		{[]string{"MET", "MSE", "XYZ", "SEP", "UNK", "FOO"}, "MMWSXX", MolProtein},
+		{[]string{"DA", "DC", "DG", "DT", "FOO"}, "ACGTN", MolNucleicAcid},
+		{[]string{"A", "PSU", "G"}, "AUG", MolNucleicAcid},
+		{[]string{"FOO"}, "X", MolProtein},
+	}
+
+	for _, tc := range testCases {
+		sequence, mol := s.OneLetterSequence(tc.names)
+		if sequence != tc.sequence || mol != tc.mol {
+			t.Errorf("OneLetterSequence(%v): expected %s %s, got %s %s", tc.names, tc.sequence, tc.mol, sequence, mol)
+		}
+	}
+}
+
+func Test_PolymerResidues(t *testing.T) {
+	s, err := ReadPDB(strings.NewReader(testSeqresPDB))
+	if err != nil {
+		t.Fatalf("ReadPDB() returned error: %v", err)
+	}
+
+	var names []string
+	for _, r := range s.PolymerResidues(s.Models[0].Chain("A")) {
+		names = append(names, r.Name+r.ID())
+	}
+	if got := strings.Join(names, " "); got != "MET1 GLY2 MSE3 ALA5" {
+		t.Errorf("Expected the polymer residues without ligands and waters, got %s", got)
+	}
+}
+
+func Test_WritePDB_sequences(t *testing.T) {
+	s, err := ReadPDB(strings.NewReader(testSeqresPDB))
+	if err != nil {
+		t.Fatalf("ReadPDB() returned error: %v", err)
+	}
+
+	for _, write := range []func(*bytes.Buffer, *Structure) error{
+		func(b *bytes.Buffer, s *Structure) error { return WritePDB(b, s) },
+		func(b *bytes.Buffer, s *Structure) error { return WriteCIF(b, s) },
+	} {
+		var buf bytes.Buffer
+		if err := write(&buf, s); err != nil {
+			t.Fatalf("Writing returned error: %v", err)
+		}
+		roundTrip, _, err := Read(&buf)
+		if err != nil {
+			t.Fatalf("Read() returned error: %v", err)
+		}
+		if !reflect.DeepEqual(roundTrip.Sequences, s.Sequences) || !reflect.DeepEqual(roundTrip.ModifiedResidues, s.ModifiedResidues) {
+			t.Errorf("Expected sequences %+v and %v, got %+v and %v", s.Sequences, s.ModifiedResidues, roundTrip.Sequences, roundTrip.ModifiedResidues)
+		}
+	}
+}
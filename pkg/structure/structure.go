@@ -0,0 +1,132 @@
+// Package structure holds macromolecular structures as a hierarchy of
+// models, chains, residues and atoms, and reads and writes them as legacy PDB
+// and PDBx/mmCIF files.
+package structure
+
+import "strconv"
+
+// Structure is a parsed structure file. Most files have a single model;
+// NMR ensembles have one per conformer.
+type Structure struct {
+	ID     string
+	Title  string
+	Models []*Model
+
+	// Method is the experimental method, e.g. X-RAY DIFFRACTION, and
+	// Resolution is in Å, or 0 if not reported.
+	Method     string
+	Resolution float64
+
+	// Sequences are the full sequences of the polymer chains, including
+	// residues without coordinates, from SEQRES records or
+	// _pdbx_poly_seq_scheme. ModifiedResidues maps the names of modified
+	// residues to their standard parents, e.g. MSE to MET, from MODRES
+	// records or _pdbx_struct_mod_residue.
+	Sequences        []*Sequence
+	ModifiedResidues map[string]string
+}
+
+// Sequence is the full sequence of a polymer chain as residue names.
+type Sequence struct {
+	ChainID  string
+	Residues []string
+}
+
+// Model is one set of coordinates for all chains.
+type Model struct {
+	Serial int
+	Chains []*Chain
+}
+
+// Chain is a set of residues sharing a chain identifier. Ligands and waters
+// assigned to the chain are part of it.
+type Chain struct {
+	ID       string
+	Residues []*Residue
+}
+
+// Residue is a residue, ligand or water molecule. It is identified within its
+// chain by sequence number, insertion code and name.
+type Residue struct {
+	Name   string
+	SeqNum int
+	ICode  string
+	HetAtm bool
+	Atoms  []*Atom
+}
+
+// Atom is a single atom. Atoms with alternate locations are all kept in
+// their residue and are told apart by AltLoc.
+type Atom struct {
+	Serial    int
+	Name      string
+	AltLoc    string
+	X, Y, Z   float64
+	Occupancy float64
+	BFactor   float64
+	Element   string
+	Charge    string
+	HetAtm    bool
+}
+
+// Sequence returns the full sequence of the chain with the given ID, or nil.
+func (s *Structure) Sequence(chainID string) *Sequence {
+	for _, seq := range s.Sequences {
+		if seq.ChainID == chainID {
+			return seq
+		}
+	}
+	return nil
+}
+
+// Atoms returns every atom of the model in file order.
+func (m *Model) Atoms() []*Atom {
+	var atoms []*Atom
+	for _, c := range m.Chains {
+		for _, r := range c.Residues {
+			atoms = append(atoms, r.Atoms...)
+		}
+	}
+	return atoms
+}
+
+// Chain returns the chain with the given ID, or nil.
+func (m *Model) Chain(id string) *Chain {
+	for _, c := range m.Chains {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// ID returns the residue's number and insertion code, e.g. 52A.
+func (r *Residue) ID() string {
+	return strconv.Itoa(r.SeqNum) + r.ICode
+}
+
+// Atom returns the atom with the given name. Among alternate locations, the
+// one with the highest occupancy is returned, the first one on ties.
+func (r *Residue) Atom(name string) *Atom {
+	var best *Atom
+	for _, a := range r.Atoms {
+		if a.Name == name && (best == nil || a.Occupancy > best.Occupancy) {
+			best = a
+		}
+	}
+	return best
+}
+
+// AltLocs returns the alternate location indicators used in the residue in
+// order of appearance.
+func (r *Residue) AltLocs() []string {
+	var altLocs []string
+	seen := make(map[string]bool)
+	for _, a := range r.Atoms {
+		if a.AltLoc != "" && !seen[a.AltLoc] {
+			seen[a.AltLoc] = true
+			altLocs = append(altLocs, a.AltLoc)
+		}
+	}
+	return altLocs
+}
@@ -0,0 +1,167 @@
+package structure
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Transform is a rotation followed by a translation.
+type Transform struct {
+	Rotation    [3][3]float64
+	Translation [3]float64
+}
+
+// Apply moves a to its transformed position.
+func (t Transform) Apply(a *Atom) {
+	x, y, z := a.X, a.Y, a.Z
+	r := t.Rotation
+	a.X = r[0][0]*x + r[0][1]*y + r[0][2]*z + t.Translation[0]
+	a.Y = r[1][0]*x + r[1][1]*y + r[1][2]*z + t.Translation[1]
+	a.Z = r[2][0]*x + r[2][1]*y + r[2][2]*z + t.Translation[2]
+}
+
+// Transform moves every atom of every model of s.
+func (s *Structure) Transform(t Transform) {
+	for _, m := range s.Models {
+		for _, a := range m.Atoms() {
+			t.Apply(a)
+		}
+	}
+}
+
+// RMSD returns the root-mean-square deviation between paired atoms.
+func RMSD(a, b []*Atom) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return math.NaN()
+	}
+	sum := 0.0
+	for i := range a {
+		dx, dy, dz := a[i].X-b[i].X, a[i].Y-b[i].Y, a[i].Z-b[i].Z
+		sum += dx*dx + dy*dy + dz*dz
+	}
+	return math.Sqrt(sum / float64(len(a)))
+}
+
+// Superpose returns the transform that moves the mobile atoms onto the paired
+// target atoms with the lowest RMSD, as in the Kabsch algorithm, and that
+// RMSD. The atoms are not moved. The rotation is found with Horn's quaternion
+// method, which never yields a reflection.
+func Superpose(mobile, target []*Atom) (Transform, float64, error) {
+	if len(mobile) != len(target) {
+		return Transform{}, 0, fmt.Errorf("cannot superpose %d atoms onto %d", len(mobile), len(target))
+	}
+	if len(mobile) < 3 {
+		return Transform{}, 0, errors.New("at least 3 atom pairs are needed for a superposition")
+	}
+
+	mobileCenter, targetCenter := centroid(mobile), centroid(target)
+
+	// s[a][b] correlates coordinate a of the centered mobile atoms with
+	// coordinate b of the centered target atoms.
+	var s [3][3]float64
+	for i := range mobile {
+		m := [3]float64{mobile[i].X - mobileCenter[0], mobile[i].Y - mobileCenter[1], mobile[i].Z - mobileCenter[2]}
+		t := [3]float64{target[i].X - targetCenter[0], target[i].Y - targetCenter[1], target[i].Z - targetCenter[2]}
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				s[a][b] += m[a] * t[b]
+			}
+		}
+	}
+
+	n := [4][4]float64{
+		{s[0][0] + s[1][1] + s[2][2], s[1][2] - s[2][1], s[2][0] - s[0][2], s[0][1] - s[1][0]},
+		{s[1][2] - s[2][1], s[0][0] - s[1][1] - s[2][2], s[0][1] + s[1][0], s[2][0] + s[0][2]},
+		{s[2][0] - s[0][2], s[0][1] + s[1][0], -s[0][0] + s[1][1] - s[2][2], s[1][2] + s[2][1]},
+		{s[0][1] - s[1][0], s[2][0] + s[0][2], s[1][2] + s[2][1], -s[0][0] - s[1][1] + s[2][2]},
+	}
+	q := largestEigenvector(n)
+
+	var t Transform
+	t.Rotation = [3][3]float64{
+		{q[0]*q[0] + q[1]*q[1] - q[2]*q[2] - q[3]*q[3], 2 * (q[1]*q[2] - q[0]*q[3]), 2 * (q[1]*q[3] + q[0]*q[2])},
+		{2 * (q[1]*q[2] + q[0]*q[3]), q[0]*q[0] - q[1]*q[1] + q[2]*q[2] - q[3]*q[3], 2 * (q[2]*q[3] - q[0]*q[1])},
+		{2 * (q[1]*q[3] - q[0]*q[2]), 2 * (q[2]*q[3] + q[0]*q[1]), q[0]*q[0] - q[1]*q[1] - q[2]*q[2] + q[3]*q[3]},
+	}
+	for i := 0; i < 3; i++ {
+		r := t.Rotation[i]
+		t.Translation[i] = targetCenter[i] - (r[0]*mobileCenter[0] + r[1]*mobileCenter[1] + r[2]*mobileCenter[2])
+	}
+
+	moved := make([]*Atom, len(mobile))
+	for i, a := range mobile {
+		copied := *a
+		t.Apply(&copied)
+		moved[i] = &copied
+	}
+	return t, RMSD(moved, target), nil
+}
+
+func centroid(atoms []*Atom) [3]float64 {
+	var c [3]float64
+	for _, a := range atoms {
+		c[0] += a.X
+		c[1] += a.Y
+		c[2] += a.Z
+	}
+	n := float64(len(atoms))
+	return [3]float64{c[0] / n, c[1] / n, c[2] / n}
+}
+
+// largestEigenvector returns the unit eigenvector of the largest eigenvalue
+// of a symmetric matrix, found with the cyclic Jacobi method.
+func largestEigenvector(a [4][4]float64) [4]float64 {
+	var v [4][4]float64
+	for i := range v {
+		v[i][i] = 1
+	}
+
+	for sweep := 0; sweep < 50; sweep++ {
+		off := 0.0
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				off += a[p][q] * a[p][q]
+			}
+		}
+		if off < 1e-22 {
+			break
+		}
+
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				// Rotate rows and columns p and q so that a[p][q] becomes 0.
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < 4; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p], a[k][q] = c*akp-s*akq, s*akp+c*akq
+				}
+				for k := 0; k < 4; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k], a[q][k] = c*apk-s*aqk, s*apk+c*aqk
+				}
+				for k := 0; k < 4; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p], v[k][q] = c*vkp-s*vkq, s*vkp+c*vkq
+				}
+			}
+		}
+	}
+
+	largest := 0
+	for i := 1; i < 4; i++ {
+		if a[i][i] > a[largest][largest] {
+			largest = i
+		}
+	}
+	return [4]float64{v[0][largest], v[1][largest], v[2][largest], v[3][largest]}
+}
@@ -0,0 +1,83 @@
+package structure
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_Superpose(t *testing.T) {
+	target := []*Atom{
+		{Name: "CA", X: 0, Y: 0, Z: 0},
+		{Name: "CA", X: 3.8, Y: 0, Z: 0},
+		{Name: "CA", X: 5.0, Y: 3.6, Z: 0},
+		{Name: "CA", X: 7.1, Y: 4.2, Z: 3.1},
+		{Name: "CA", X: 9.9, Y: 2.0, Z: 4.4},
+	}
+
+	// Rotate by 90 degrees about z and then 30 degrees about x, and move.
+	cos, sin := math.Cos(math.Pi/6), math.Sin(math.Pi/6)
+	moving := Transform{
+		Rotation: [3][3]float64{
+			{0, -1, 0},
+			{cos, 0, -sin},
+			{sin, 0, cos},
+		},
+		Translation: [3]float64{10, -4, 2.5},
+	}
+	mobile := make([]*Atom, len(target))
+	for i, a := range target {
+		copied := *a
+		moving.Apply(&copied)
+		mobile[i] = &copied
+	}
+	if rmsd := RMSD(mobile, target); rmsd < 1 {
+		t.Fatalf("RMSD(): expected the moved atoms to differ, got %.3f", rmsd)
+	}
+
+	transform, rmsd, err := Superpose(mobile, target)
+	if err != nil {
+		t.Fatalf("Superpose() returned error: %v", err)
+	}
+	if rmsd > 1e-6 {
+		t.Errorf("Superpose(): expected an RMSD of 0, got %g", rmsd)
+	}
+	for _, a := range mobile {
+		transform.Apply(a)
+	}
+	if rmsd := RMSD(mobile, target); rmsd > 1e-6 {
+		t.Errorf("Transform.Apply(): expected an RMSD of 0, got %g", rmsd)
+	}
+
+	if _, _, err := Superpose(mobile[:2], target[:2]); err == nil {
+		t.Errorf("Superpose(): expected an error for 2 atom pairs")
+	}
+	if _, _, err := Superpose(mobile, target[:4]); err == nil {
+		t.Errorf("Superpose(): expected an error for unpaired atoms")
+	}
+}
+
+func Test_Superpose_mirror(t *testing.T) {
+	target := []*Atom{
+		{X: 0, Y: 0, Z: 0}, {X: 1, Y: 0, Z: 0}, {X: 0, Y: 1, Z: 0}, {X: 0, Y: 0, Z: 1},
+	}
+	mirrored := make([]*Atom, len(target))
+	for i, a := range target {
+		mirrored[i] = &Atom{X: a.X, Y: a.Y, Z: -a.Z}
+	}
+
+	// A mirror image cannot be superposed by a rotation.
+	transform, rmsd, err := Superpose(mirrored, target)
+	if err != nil {
+		t.Fatalf("Superpose() returned error: %v", err)
+	}
+	if rmsd < 0.1 {
+		t.Errorf("Superpose(): expected a mirror image not to fit, got RMSD %g", rmsd)
+	}
+	r := transform.Rotation
+	det := r[0][0]*(r[1][1]*r[2][2]-r[1][2]*r[2][1]) -
+		r[0][1]*(r[1][0]*r[2][2]-r[1][2]*r[2][0]) +
+		r[0][2]*(r[1][0]*r[2][1]-r[1][1]*r[2][0])
+	if math.Abs(det-1) > 1e-9 {
+		t.Errorf("Superpose(): expected a rotation, got determinant %g", det)
+	}
+}
@@ -0,0 +1,300 @@
+package structure
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WritePDB writes s in legacy PDB format. Models are only delimited by
+// MODEL records if there are several. Chain IDs longer than one character,
+// residue names longer than three, residue numbers beyond four digits and
+// coordinates beyond -999.999 to 9999.999 Å do not fit the format and are
+// reported as errors; atom serial numbers wrap around.
+func WritePDB(w io.Writer, s *Structure) error {
+	bw := bufio.NewWriter(w)
+
+	if s.ID != "" {
+		fmt.Fprintf(bw, "HEADER    %-40s%-9s   %-4s\n", "", "", s.ID)
+	}
+	for i, line := range wrapText(s.Title, 70) {
+		if i == 0 {
+			fmt.Fprintf(bw, "TITLE     %s\n", line)
+		} else {
+			fmt.Fprintf(bw, "TITLE   %2d %s\n", i+1, line)
+		}
+	}
+	if s.Method != "" {
+		fmt.Fprintf(bw, "EXPDTA    %s\n", s.Method)
+	}
+	if s.Resolution > 0 {
+		fmt.Fprintf(bw, "REMARK   2 RESOLUTION.  %6.2f ANGSTROMS.\n", s.Resolution)
+	}
+
+	for _, seq := range s.Sequences {
+		if len(seq.ChainID) > 1 {
+			return fmt.Errorf("chain ID %q does not fit in PDB format", seq.ChainID)
+		}
+		for _, name := range seq.Residues {
+			if len(name) > 3 {
+				return fmt.Errorf("residue name %q in the sequence of chain %s does not fit in PDB format", name, seq.ChainID)
+			}
+		}
+		for i := 0; i < len(seq.Residues); i += 13 {
+			end := i + 13
+			if end > len(seq.Residues) {
+				end = len(seq.Residues)
+			}
+			names := make([]string, end-i)
+			for j, name := range seq.Residues[i:end] {
+				names[j] = fmt.Sprintf("%3s", name)
+			}
+			fmt.Fprintf(bw, "SEQRES %3d %1s %4d  %s\n", i/13+1, seq.ChainID, len(seq.Residues), strings.Join(names, " "))
+		}
+	}
+	for _, mod := range modifiedResidues(s) {
+		fmt.Fprintf(bw, "MODRES %-4s %3s %1s %4d%1s %3s\n", s.ID, mod.residue.Name, mod.chain.ID, mod.residue.SeqNum, mod.residue.ICode, mod.parent)
+	}
+
+	for _, m := range s.Models {
+		if len(s.Models) > 1 {
+			fmt.Fprintf(bw, "MODEL     %4d\n", m.Serial)
+		}
+		serial := 0
+		for _, c := range m.Chains {
+			if len(c.ID) > 1 {
+				return fmt.Errorf("chain ID %q does not fit in PDB format", c.ID)
+			}
+			// TER follows the last polymer residue of the chain, before its
+			// ligands and waters.
+			last := -1
+			for i, r := range c.Residues {
+				if !r.HetAtm {
+					last = i
+				}
+			}
+			for i, r := range c.Residues {
+				if r.SeqNum > 9999 || r.SeqNum < -999 {
+					return fmt.Errorf("residue number %d of chain %s does not fit in PDB format", r.SeqNum, c.ID)
+				}
+				if len(r.Name) > 3 {
+					return fmt.Errorf("residue name %q of chain %s does not fit in PDB format", r.Name, c.ID)
+				}
+				for _, a := range r.Atoms {
+					if !pdbCoordinateFits(a.X) || !pdbCoordinateFits(a.Y) || !pdbCoordinateFits(a.Z) {
+						return fmt.Errorf("coordinates of atom %s of %s %s%s do not fit in PDB format", a.Name, r.Name, c.ID, r.ID())
+					}
+					serial++
+					writePDBAtom(bw, serial, c.ID, r, a)
+				}
+				if i == last {
+					serial++
+					ter := fmt.Sprintf("TER   %5d      %3s %1s%4d%1s", serial%100000, r.Name, c.ID, r.SeqNum, r.ICode)
+					fmt.Fprintln(bw, strings.TrimRight(ter, " "))
+				}
+			}
+		}
+		if len(s.Models) > 1 {
+			fmt.Fprintln(bw, "ENDMDL")
+		}
+	}
+	fmt.Fprintln(bw, "END")
+	return bw.Flush()
+}
+
+// modifiedResidue is an occurrence of a modified residue in the first model.
+type modifiedResidue struct {
+	chain   *Chain
+	residue *Residue
+	parent  string
+}
+
+func modifiedResidues(s *Structure) []modifiedResidue {
+	if len(s.Models) == 0 || len(s.ModifiedResidues) == 0 {
+		return nil
+	}
+	var modified []modifiedResidue
+	for _, c := range s.Models[0].Chains {
+		for _, r := range c.Residues {
+			if parent, ok := s.ModifiedResidues[r.Name]; ok {
+				modified = append(modified, modifiedResidue{c, r, parent})
+			}
+		}
+	}
+	return modified
+}
+
+func writePDBAtom(w io.Writer, serial int, chainID string, r *Residue, a *Atom) {
+	record := "ATOM"
+	if a.HetAtm {
+		record = "HETATM"
+	}
+	fmt.Fprintf(w, "%-6s%5d %-4s%1s%3s %1s%4d%1s   %8.3f%8.3f%8.3f%6.2f%6.2f          %2s%2s\n",
+		record, serial%100000, pdbAtomName(a), a.AltLoc, r.Name, chainID, r.SeqNum, r.ICode,
+		a.X, a.Y, a.Z, a.Occupancy, a.BFactor, a.Element, a.Charge)
+}
+
+// pdbCoordinateFits reports whether a coordinate fits in the eight columns
+// of its %8.3f field.
+func pdbCoordinateFits(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0) && len(strconv.FormatFloat(v, 'f', 3, 64)) <= 8
+}
+
+// pdbAtomName aligns an atom name in its four columns: names of atoms with a
+// one-letter element start in the second column, so that the element symbol
+// lines up with two-letter ones.
+func pdbAtomName(a *Atom) string {
+	if len(a.Name) < 4 && len(a.Element) < 2 {
+		return " " + a.Name
+	}
+	return a.Name
+}
+
+// wrapText splits text into lines of at most width characters at spaces.
+func wrapText(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// atomSiteItems are the _atom_site items written by WriteCIF. The label_
+// items repeat the author's chain IDs and numbering, since a Structure does
+// not keep the PDB's own ones.
+var atomSiteItems = []string{
+	"group_PDB", "id", "type_symbol", "label_atom_id", "label_alt_id", "label_comp_id",
+	"label_asym_id", "label_seq_id", "pdbx_PDB_ins_code", "Cartn_x", "Cartn_y", "Cartn_z",
+	"occupancy", "B_iso_or_equiv", "pdbx_formal_charge", "auth_seq_id", "auth_comp_id",
+	"auth_asym_id", "auth_atom_id", "pdbx_PDB_model_num",
+}
+
+// WriteCIF writes s in PDBx/mmCIF format.
+func WriteCIF(w io.Writer, s *Structure) error {
+	bw := bufio.NewWriter(w)
+
+	id := s.ID
+	if id == "" {
+		id = "structure"
+	}
+	fmt.Fprintf(bw, "data_%s\n#\n", strings.Join(strings.Fields(id), "_"))
+	if s.ID != "" {
+		fmt.Fprintf(bw, "_entry.id %s\n#\n", cifQuote(s.ID))
+	}
+	if s.Title != "" {
+		fmt.Fprintf(bw, "_struct.title %s\n#\n", cifQuote(s.Title))
+	}
+	if s.Method != "" {
+		fmt.Fprintf(bw, "_exptl.method %s\n#\n", cifQuote(s.Method))
+	}
+	if s.Resolution > 0 {
+		item := "_refine.ls_d_res_high"
+		if strings.Contains(s.Method, "ELECTRON MICROSCOPY") {
+			item = "_em_3d_reconstruction.resolution"
+		}
+		fmt.Fprintf(bw, "%s %s\n#\n", item, strconv.FormatFloat(s.Resolution, 'f', -1, 64))
+	}
+
+	if len(s.Sequences) > 0 {
+		fmt.Fprintln(bw, "loop_")
+		for _, item := range []string{"asym_id", "seq_id", "mon_id", "pdb_strand_id"} {
+			fmt.Fprintf(bw, "_pdbx_poly_seq_scheme.%s\n", item)
+		}
+		for _, seq := range s.Sequences {
+			for i, name := range seq.Residues {
+				fmt.Fprintf(bw, "%s %d %s %s\n", cifQuote(seq.ChainID), i+1, cifQuote(name), cifQuote(seq.ChainID))
+			}
+		}
+		fmt.Fprintln(bw, "#")
+	}
+	if modified := modifiedResidues(s); len(modified) > 0 {
+		fmt.Fprintln(bw, "loop_")
+		for _, item := range []string{"id", "auth_asym_id", "auth_seq_id", "PDB_ins_code", "auth_comp_id", "parent_comp_id"} {
+			fmt.Fprintf(bw, "_pdbx_struct_mod_residue.%s\n", item)
+		}
+		for i, mod := range modified {
+			fmt.Fprintf(bw, "%d %s %d %s %s %s\n", i+1, cifQuote(mod.chain.ID), mod.residue.SeqNum,
+				cifNull(mod.residue.ICode, "?"), cifQuote(mod.residue.Name), cifQuote(mod.parent))
+		}
+		fmt.Fprintln(bw, "#")
+	}
+
+	fmt.Fprintln(bw, "loop_")
+	for _, item := range atomSiteItems {
+		fmt.Fprintf(bw, "_atom_site.%s\n", item)
+	}
+
+	serial := 0
+	for _, m := range s.Models {
+		for _, c := range m.Chains {
+			for _, r := range c.Residues {
+				for _, a := range r.Atoms {
+					serial++
+					group, seqID := "ATOM", strconv.Itoa(r.SeqNum)
+					if a.HetAtm {
+						group, seqID = "HETATM", "."
+					}
+					values := []string{
+						group, strconv.Itoa(serial), cifQuote(a.Element), cifQuote(a.Name), cifNull(a.AltLoc, "."),
+						cifQuote(r.Name), cifQuote(c.ID), seqID, cifNull(r.ICode, "?"),
+						strconv.FormatFloat(a.X, 'f', 3, 64), strconv.FormatFloat(a.Y, 'f', 3, 64), strconv.FormatFloat(a.Z, 'f', 3, 64),
+						strconv.FormatFloat(a.Occupancy, 'f', 2, 64), strconv.FormatFloat(a.BFactor, 'f', 2, 64),
+						cifNull(cifCharge(a.Charge), "?"), strconv.Itoa(r.SeqNum), cifQuote(r.Name),
+						cifQuote(c.ID), cifQuote(a.Name), strconv.Itoa(m.Serial),
+					}
+					fmt.Fprintln(bw, strings.Join(values, " "))
+				}
+			}
+		}
+	}
+	fmt.Fprintln(bw, "#")
+	return bw.Flush()
+}
+
+// cifNull writes an empty value as the given null value, . or ?.
+func cifNull(value, null string) string {
+	if value == "" {
+		return null
+	}
+	return cifQuote(value)
+}
+
+// cifQuote quotes a value if it would otherwise be read as something else.
+func cifQuote(value string) string {
+	if value == "" {
+		return "?"
+	}
+	if strings.Contains(value, "\n") {
+		return "\n;" + value + "\n;"
+	}
+
+	lower := strings.ToLower(value)
+	bare := !strings.ContainsAny(value, " \t") &&
+		!strings.ContainsAny(value[:1], "_#$'\";[]") &&
+		value != "." && value != "?" && lower != "loop_" && lower != "stop_" && lower != "global_" &&
+		!strings.HasPrefix(lower, "data_") && !strings.HasPrefix(lower, "save_")
+	switch {
+	case bare:
+		return value
+	case !strings.Contains(value, "' "):
+		return "'" + value + "'"
+	case !strings.Contains(value, "\" "):
+		return "\"" + value + "\""
+	default:
+		return "\n;" + value + "\n;"
+	}
+}
@@ -0,0 +1,188 @@
+package structure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_WritePDB(t *testing.T) {
+	s, err := ReadPDB(strings.NewReader(testPDB))
+	if err != nil {
+		t.Fatalf("ReadPDB() returned error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePDB(&buf, s); err != nil {
+		t.Fatalf("WritePDB() returned error: %v", err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	expected := []string{
+		"ATOM      1  N   VAL A   1       6.204  16.869   4.854  1.00 49.05           N  ",
+		"HETATM    7 FE   HEM A 201      11.000  21.000   8.000  1.00 15.00          FE  ",
+		"HETATM    8  O   HOH A 301      12.000  22.000   9.000  0.50 35.00           O1-",
+		"TER       6      SER A  52A",
+	}
+	for _, line := range expected {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, buf.String())
+		}
+	}
+	if lines[0][62:66] != "1ABC" {
+		t.Errorf("Expected the ID in columns 63-66 of %q", lines[0])
+	}
+
+	roundTrip, err := ReadPDB(&buf)
+	if err != nil {
+		t.Fatalf("ReadPDB() of written file returned error: %v", err)
+	}
+	assertSameStructure(t, s, roundTrip)
+}
+
+func Test_WritePDB_errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		chainID string
+		resName string
+		seqNum  int
+		x       float64
+		err     bool
+	}{
+		{"Long chain ID", "AA", "GLY", 1, 0, true},
+		{"Large residue number", "A", "GLY", 10000, 0, true},
+		{"Long residue name", "A", "A1AAA", 1, 0, true},
+		{"Large coordinate", "A", "GLY", 1, 10000, true},
+		{"Small coordinate", "A", "GLY", 1, -1000, true},
+		{"Largest coordinate", "A", "GLY", 1, 9999.999, false},
+		{"Smallest coordinate", "A", "GLY", 1, -999.999, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newBuilder()
+			b.addAtom(tc.chainID, tc.resName, tc.seqNum, "", &Atom{Name: "CA", Element: "C", X: tc.x})
+			if err := WritePDB(&bytes.Buffer{}, b.structure); (err != nil) != tc.err {
+				t.Errorf("Expected error: %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	b := newBuilder()
+	b.addSeqRes("A", "A1AAA")
+	b.addAtom("A", "GLY", 1, "", &Atom{Name: "CA", Element: "C"})
+	if err := WritePDB(&bytes.Buffer{}, b.structure); err == nil {
+		t.Errorf("Expected an error for a long residue name in SEQRES")
+	}
+}
+
+func Test_WriteCIF(t *testing.T) {
+	for _, input := range []string{testPDB, testCIF} {
+		s, _, err := Read(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Read() returned error: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := WriteCIF(&buf, s); err != nil {
+			t.Fatalf("WriteCIF() returned error: %v", err)
+		}
+		roundTrip, err := ReadCIF(&buf)
+		if err != nil {
+			t.Fatalf("ReadCIF() of written file returned error: %v\n%s", err, buf.String())
+		}
+		assertSameStructure(t, s, roundTrip)
+	}
+}
+
+func Test_Read(t *testing.T) {
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(testCIF))
+	gz.Close()
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected Format
+	}{
+		{"PDB", testPDB, FormatPDB},
+		{"mmCIF", testCIF, FormatCIF},
+		{"mmCIF with leading comments", "# generated\n\n" + testCIF, FormatCIF},
+		{"Gzipped mmCIF", gzipped.String(), FormatCIF},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, format, err := Read(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatalf("Read() returned error: %v", err)
+			}
+			if format != tc.expected || s.ID != "1ABC" {
+				t.Errorf("Expected %s entry 1ABC, got %s entry %q", tc.expected, format, s.ID)
+			}
+		})
+	}
+}
+
+func Test_cifQuote(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected string
+	}{
+		{"CA", "CA"},
+		{"", "?"},
+		{".", "'.'"},
+		{"X-RAY DIFFRACTION", "'X-RAY DIFFRACTION'"},
+		{"_tag", "'_tag'"},
+		{"data_x", "'data_x'"},
+		{"O5'", "O5'"},
+		{"it' s", "\"it' s\""},
+		{"two\nlines", "\n;two\nlines\n;"},
+	}
+
+	for _, tc := range testCases {
+		if quoted := cifQuote(tc.value); quoted != tc.expected {
+			t.Errorf("cifQuote(%q): expected %q, got %q", tc.value, tc.expected, quoted)
+		}
+	}
+}
+
+func Test_ParseFormat(t *testing.T) {
+	for name, expected := range map[string]Format{"pdb": FormatPDB, "mmCIF": FormatCIF, "cif": FormatCIF} {
+		if format, err := ParseFormat(name); err != nil || format != expected {
+			t.Errorf("ParseFormat(%q): expected %s, got %s, %v", name, expected, format, err)
+		}
+	}
+	if _, err := ParseFormat("xyz"); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
+
+// assertSameStructure compares the atoms and metadata of two structures,
+// ignoring serial numbers, which writers renumber.
+func assertSameStructure(t *testing.T, expected, got *Structure) {
+	t.Helper()
+	if expected.ID != got.ID || expected.Title != got.Title || expected.Method != got.Method || expected.Resolution != got.Resolution {
+		t.Errorf("Metadata differs: expected %q %q %q %v, got %q %q %q %v",
+			expected.ID, expected.Title, expected.Method, expected.Resolution, got.ID, got.Title, got.Method, got.Resolution)
+	}
+	if len(expected.Models) != len(got.Models) {
+		t.Fatalf("Expected %d models, got %d", len(expected.Models), len(got.Models))
+	}
+	for i := range expected.Models {
+		expectedAtoms, gotAtoms := expected.Models[i].Atoms(), got.Models[i].Atoms()
+		if len(expectedAtoms) != len(gotAtoms) {
+			t.Fatalf("Model %d: expected %d atoms, got %d", i+1, len(expectedAtoms), len(gotAtoms))
+		}
+		for j := range expectedAtoms {
+			a, b := *expectedAtoms[j], *gotAtoms[j]
+			a.Serial, b.Serial = 0, 0
+			if !reflect.DeepEqual(a, b) {
+				t.Errorf("Model %d atom %d: expected %+v, got %+v", i+1, j+1, a, b)
+			}
+		}
+	}
+}